@@ -0,0 +1,358 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/database"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var bundleCollection *mongo.Collection = database.OpenCollection(database.Client, "bundle")
+
+// GetBundles lists the bundles, ?menu_id= lists the bundles of one menu.
+func GetBundles() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		filter := bson.M{}
+		if menuId := ctx.Query("menu_id"); menuId != "" {
+			filter["menu_id"] = menuId
+		}
+
+		result, err := bundleCollection.Find(c, filter)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while listing Bundles"})
+			return
+		}
+
+		var allBundles []bson.M
+		if err = result.All(c, &allBundles); err != nil {
+			log.Println(err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while listing Bundles"})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, allBundles)
+	}
+}
+
+func GetBundle() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var bundle models.Bundle
+		if err := bundleCollection.FindOne(c, bson.M{"bundle_id": ctx.Param("bundle_id")}).Decode(&bundle); err != nil {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Bundle was not found"})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, bundle)
+	}
+}
+
+func CreateBundle() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var bundle models.Bundle
+
+		if err := ctx.BindJSON(&bundle); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validationErr := validate.Struct(bundle)
+		if validationErr != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		if err := validateBundle(c, &bundle); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		bundle.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		bundle.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		bundle.ID = primitive.NewObjectID()
+		bundle.Bundle_id = bundle.ID.Hex()
+
+		result, insertErr := bundleCollection.InsertOne(c, bundle)
+		if insertErr != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Bundle was not created"})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, result)
+	}
+}
+
+// UpdateBundle changes a bundle. The fields sent replace those of the
+// bundle, which is then checked as a whole, slots are replaced together.
+// Items already ordered keep the price they were ordered at.
+func UpdateBundle() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var request models.Bundle
+		var bundle models.Bundle
+		bundleId := ctx.Param("bundle_id")
+
+		if err := ctx.BindJSON(&request); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := bundleCollection.FindOne(c, bson.M{"bundle_id": bundleId}).Decode(&bundle); err != nil {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Bundle was not found"})
+			return
+		}
+
+		if request.Name != nil {
+			bundle.Name = request.Name
+		}
+		if request.Menu_id != nil {
+			bundle.Menu_id = request.Menu_id
+		}
+		if request.Pricing != "" {
+			bundle.Pricing = request.Pricing
+		}
+		if request.Price != nil {
+			bundle.Price = request.Price
+		}
+		if request.Percent_off != nil {
+			bundle.Percent_off = request.Percent_off
+		}
+		if request.Slots != nil {
+			bundle.Slots = request.Slots
+		}
+
+		if validationErr := validate.Struct(bundle); validationErr != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		if err := validateBundle(c, &bundle); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		bundle.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		var updateObj primitive.D
+		updateObj = append(updateObj, bson.E{"name", bundle.Name})
+		updateObj = append(updateObj, bson.E{"menu_id", bundle.Menu_id})
+		updateObj = append(updateObj, bson.E{"pricing", bundle.Pricing})
+		updateObj = append(updateObj, bson.E{"price", bundle.Price})
+		updateObj = append(updateObj, bson.E{"percent_off", bundle.Percent_off})
+		updateObj = append(updateObj, bson.E{"slots", bundle.Slots})
+		updateObj = append(updateObj, bson.E{"updated_at", bundle.Updated_at})
+
+		result, err := bundleCollection.UpdateOne(
+			c,
+			bson.M{"bundle_id": bundleId},
+			bson.D{
+				{"$set", updateObj},
+			},
+		)
+		if err != nil {
+			msg := fmt.Sprintf("Bundle update failed")
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, result)
+	}
+}
+
+// validateBundle checks that the menu and every food of a bundle exist,
+// and gives every slot without an id one. A fixed price must be positive
+// and supplements in its currency.
+func validateBundle(c context.Context, bundle *models.Bundle) error {
+	count, err := menuCollection.CountDocuments(c, bson.M{"menu_id": bundle.Menu_id})
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("Menu %s does not exist", *bundle.Menu_id)
+	}
+
+	if bundle.Pricing == models.BundleFixed {
+		if !bundle.Price.IsPositive() {
+			return fmt.Errorf("Price of a FIXED bundle must be positive")
+		}
+	} else {
+		bundle.Price = nil
+	}
+	if bundle.Pricing != models.BundlePercentOff {
+		bundle.Percent_off = nil
+	}
+
+	slotIds := map[string]bool{}
+	for i := range bundle.Slots {
+		slot := &bundle.Slots[i]
+		if slot.Slot_id == "" {
+			slot.Slot_id = primitive.NewObjectID().Hex()
+		}
+		if slotIds[slot.Slot_id] {
+			return fmt.Errorf("Slot %s is on the bundle more than once", slot.Slot_id)
+		}
+		slotIds[slot.Slot_id] = true
+
+		foodIds := bson.A{}
+		eligible := map[string]bool{}
+		for _, foodId := range slot.Food_ids {
+			if !eligible[foodId] {
+				foodIds = append(foodIds, foodId)
+			}
+			eligible[foodId] = true
+		}
+		count, err := foodCollection.CountDocuments(c, bson.M{"food_id": bson.M{"$in": foodIds}})
+		if err != nil {
+			return err
+		}
+		if int(count) != len(foodIds) {
+			return fmt.Errorf("Some foods of slot %s do not exist", slot.Name)
+		}
+
+		for foodId, supplement := range slot.Supplements {
+			if !eligible[foodId] {
+				return fmt.Errorf("Food %s has a supplement but is not in slot %s", foodId, slot.Name)
+			}
+			if supplement.IsNegative() {
+				return fmt.Errorf("Supplements of slot %s cannot be negative", slot.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// pricedBundle is a bundle as ordered, with the food picked for each of its
+// slots and what one of it costs.
+type pricedBundle struct {
+	bundle     models.Bundle
+	components []models.BundleComponent
+	foods      map[string]models.Food
+	price      models.Money
+}
+
+// orderableBundle checks that a bundle can be ordered right now with the
+// foods picked for it, and prices it. Every slot is filled exactly once,
+// with a food that can be ordered itself.
+func orderableBundle(c context.Context, bundleId string, selected []models.BundleComponent) (pricedBundle, error) {
+	var priced pricedBundle
+	var menu models.Menu
+
+	if err := bundleCollection.FindOne(c, bson.M{"bundle_id": bundleId}).Decode(&priced.bundle); err != nil {
+		return priced, fmt.Errorf("Bundle %s was not found", bundleId)
+	}
+	bundle := priced.bundle
+
+	if err := menuCollection.FindOne(c, bson.M{"menu_id": bundle.Menu_id}).Decode(&menu); err != nil {
+		return priced, fmt.Errorf("Menu of bundle %s was not found", bundleId)
+	}
+	if !menuIsActive(menu, time.Now()) {
+		return priced, fmt.Errorf("%s is not available right now, the %s menu is closed", *bundle.Name, menu.Name)
+	}
+
+	picked := map[string]models.BundleComponent{}
+	for _, component := range selected {
+		if _, ok := picked[component.Slot_id]; ok {
+			return priced, fmt.Errorf("Slot %s of %s is filled more than once", component.Slot_id, *bundle.Name)
+		}
+		picked[component.Slot_id] = component
+	}
+	if len(picked) != len(bundle.Slots) {
+		return priced, fmt.Errorf("Pick one food for each of the %d slots of %s", len(bundle.Slots), *bundle.Name)
+	}
+
+	var foodsPrice, extras models.Money
+	priced.foods = map[string]models.Food{}
+	for _, slot := range bundle.Slots {
+		component, ok := picked[slot.Slot_id]
+		if !ok {
+			return priced, fmt.Errorf("Pick a food for %s of %s", slot.Name, *bundle.Name)
+		}
+
+		eligible := false
+		for _, foodId := range slot.Food_ids {
+			eligible = eligible || foodId == component.Food_id
+		}
+		if !eligible {
+			return priced, fmt.Errorf("Food %s cannot be picked for %s of %s", component.Food_id, slot.Name, *bundle.Name)
+		}
+
+		food, err := orderableFood(c, component.Food_id)
+		if err != nil {
+			return priced, err
+		}
+		price, modifiers, err := modifiedUnitPrice(food, models.DefaultPortion, component.Modifiers)
+		if err != nil {
+			return priced, err
+		}
+		basePrice, _ := foodUnitPrice(food, models.DefaultPortion)
+
+		component.Slot_name = slot.Name
+		component.Name = foodName(food)
+		component.Modifiers = modifiers
+		component.Supplement = slot.Supplements[food.Food_id]
+		priced.components = append(priced.components, component)
+		priced.foods[food.Food_id] = food
+
+		foodsPrice = foodsPrice.Add(basePrice)
+		extras = extras.Add(component.Supplement).Add(price.Sub(basePrice))
+	}
+
+	var err error
+	priced.price, err = bundlePrice(bundle, foodsPrice, extras)
+	return priced, err
+}
+
+// bundlePrice prices a bundle from the regular price of the foods picked for
+// it and the supplements and modifiers on top. Extras are always charged in
+// full, a percentage off only applies to the foods.
+func bundlePrice(bundle models.Bundle, foodsPrice models.Money, extras models.Money) (models.Money, error) {
+	var price models.Money
+	if bundle.Pricing == models.BundleFixed {
+		price = *bundle.Price
+	} else {
+		price = foodsPrice.Sub(foodsPrice.Percent(*bundle.Percent_off))
+	}
+	if !extras.IsZero() && extras.Currency != price.Currency {
+		return price, fmt.Errorf("Supplements and modifiers of %s are not in %s", *bundle.Name, price.Currency)
+	}
+
+	return price.Add(extras), nil
+}
+
+// stockUsage is what quantity of the bundle takes from stock.
+func (priced pricedBundle) stockUsage(quantity int) []models.RecipeItem {
+	var usage []models.RecipeItem
+	for _, component := range priced.components {
+		usage = append(usage, stockUsage(priced.foods[component.Food_id], models.DefaultPortion, quantity)...)
+	}
+
+	return usage
+}
+
+func componentNames(components []models.BundleComponent) []string {
+	names := make([]string, 0, len(components))
+	for _, component := range components {
+		names = append(names, component.Name)
+	}
+
+	return names
+}
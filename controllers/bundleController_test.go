@@ -0,0 +1,42 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/models"
+)
+
+func TestBundlePrice(t *testing.T) {
+	usd := func(amount int64) models.Money { return models.NewMoney(amount, "USD") }
+	name := "Lunch deal"
+	fixed := usd(1200)
+	tenPercent := 10.0
+	thirdOff := 33.0
+
+	tests := []struct {
+		name       string
+		bundle     models.Bundle
+		foodsPrice models.Money
+		extras     models.Money
+		want       models.Money
+		wantErr    bool
+	}{
+		{"fixed ignores the foods", models.Bundle{Name: &name, Pricing: models.BundleFixed, Price: &fixed}, usd(1999), models.Money{}, usd(1200), false},
+		{"fixed plus extras", models.Bundle{Name: &name, Pricing: models.BundleFixed, Price: &fixed}, usd(1999), usd(250), usd(1450), false},
+		{"percent off the foods", models.Bundle{Name: &name, Pricing: models.BundlePercentOff, Percent_off: &tenPercent}, usd(2000), models.Money{}, usd(1800), false},
+		{"percent off rounds the discount", models.Bundle{Name: &name, Pricing: models.BundlePercentOff, Percent_off: &thirdOff}, usd(1001), models.Money{}, usd(671), false},
+		{"extras are not discounted", models.Bundle{Name: &name, Pricing: models.BundlePercentOff, Percent_off: &tenPercent}, usd(2000), usd(300), usd(2100), false},
+		{"extras in another currency", models.Bundle{Name: &name, Pricing: models.BundleFixed, Price: &fixed}, usd(1999), models.NewMoney(100, "EUR"), models.Money{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := bundlePrice(tt.bundle, tt.foodsPrice, tt.extras)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: bundlePrice error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("%s: bundlePrice = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"math"
 	"net/http"
 	"strconv"
 	"time"
@@ -22,9 +21,12 @@ import (
 var foodCollection *mongo.Collection = database.OpenCollection(database.Client, "food")
 var validate = validator.New()
 
+// GetFoods lists foods a page at a time. Every food is flagged sold_out when
+// it has been 86'd or has no portions left, ?available=true leaves those out.
 func GetFoods() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
 
 		recordPerPage, err := strconv.Atoi(ctx.Query("recordPerPage"))
 		if err != nil || recordPerPage < 1 {
@@ -37,28 +39,40 @@ func GetFoods() gin.HandlerFunc {
 		}
 
 		startIndex := (page - 1) * recordPerPage
-		startIndex, err = strconv.Atoi(ctx.Query("startIndex"))
+		if index, err := strconv.Atoi(ctx.Query("startIndex")); err == nil && index >= 0 {
+			startIndex = index
+		}
 
-		matchStage := bson.D{{"$match", bson.D{{}}}}
-		groupStage := bson.D{{"$group", bson.D{{"_id", bson.D{{"_id", "null"}}}, {"total_count", bson.D{{"$sum", "1"}}}, {"data", bson.D{{"$push", "$$ROOT"}}}}}}
+		soldOutStage := bson.D{{"$addFields", bson.D{{"sold_out", soldOutExpression}}}}
+		matchStage := bson.D{{"$match", bson.D{}}}
+		if ctx.Query("available") == "true" {
+			matchStage = bson.D{{"$match", bson.D{{"sold_out", false}}}}
+		}
+		groupStage := bson.D{{"$group", bson.D{{"_id", nil}, {"total_count", bson.D{{"$sum", 1}}}, {"data", bson.D{{"$push", "$$ROOT"}}}}}}
 		projectState := bson.D{
 			{
 				"$project", bson.D{
-					{"id", 0},
+					{"_id", 0},
 					{"total_count", 1},
-					{"food_items", bson.D{{"$slice", []interface{}{"data", startIndex, recordPerPage}}}},
+					{"food_items", bson.D{{"$slice", []interface{}{"$data", startIndex, recordPerPage}}}},
 				},
 			},
 		}
 
-		result, err := foodCollection.Aggregate(c, mongo.Pipeline{matchStage, groupStage, projectState})
-		defer cancel()
+		result, err := foodCollection.Aggregate(c, mongo.Pipeline{soldOutStage, matchStage, groupStage, projectState})
 		if err != nil {
 			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Errow occured while fetching Food Items........"})
+			return
 		}
 		var allFoods []bson.M
 		if err = result.All(c, &allFoods); err != nil {
-			log.Fatal(err)
+			log.Println(err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Errow occured while fetching Food Items........"})
+			return
+		}
+		if len(allFoods) == 0 {
+			ctx.JSON(http.StatusOK, gin.H{"total_count": 0, "food_items": []bson.M{}})
+			return
 		}
 
 		ctx.JSON(http.StatusOK, allFoods[0])
@@ -85,6 +99,7 @@ func GetFood() gin.HandlerFunc {
 func CreateFood() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
 		var food models.Food
 		var menu models.Menu
 
@@ -100,7 +115,6 @@ func CreateFood() gin.HandlerFunc {
 		}
 
 		err := menuCollection.FindOne(c, bson.M{"menu_id": food.Menu_id}).Decode(&menu)
-		defer cancel()
 		if err != nil {
 			msg := fmt.Sprintf("Menu not available")
 			ctx.JSON(http.StatusInternalServerError, gin.H{"error": msg})
@@ -110,8 +124,21 @@ func CreateFood() gin.HandlerFunc {
 		food.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		food.ID = primitive.NewObjectID()
 		food.Food_id = food.ID.Hex()
-		var num = ToFixed(*food.Price, 2)
-		food.Price = &num
+
+		if err := validateFoodPrices(*food.Price, food.Portion_prices); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := validateRecipes(c, food.Recipe, food.Portion_recipes); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := validateModifierGroups(food.Modifier_groups, *food.Price); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 
 		result, insertErr := foodCollection.InsertOne(c, food)
 		if insertErr != nil {
@@ -120,7 +147,6 @@ func CreateFood() gin.HandlerFunc {
 			return
 		}
 
-		defer cancel()
 		ctx.JSON(http.StatusOK, result)
 
 	}
@@ -129,18 +155,56 @@ func CreateFood() gin.HandlerFunc {
 func UpdateFood() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
 		var menu models.Menu
 		var food models.Food
 		var updateObj primitive.D
 
 		foodId := ctx.Param("food_id")
 
+		if err := ctx.BindJSON(&food); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
 		if food.Name != nil {
 			updateObj = append(updateObj, bson.E{"name", food.Name})
 		}
 
-		if food.Price != nil {
-			updateObj = append(updateObj, bson.E{"price", food.Price})
+		if food.Price != nil || food.Portion_prices != nil {
+			var foundFood models.Food
+			if err := foodCollection.FindOne(c, bson.M{"food_id": foodId}).Decode(&foundFood); err != nil {
+				ctx.JSON(http.StatusNotFound, gin.H{"error": "Food Item was not found"})
+				return
+			}
+
+			price, portionPrices := foundFood.Price, foundFood.Portion_prices
+			if food.Price != nil {
+				price = food.Price
+			}
+			if food.Portion_prices != nil {
+				if validationErr := validate.StructPartial(food, "Portion_prices"); validationErr != nil {
+					ctx.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+					return
+				}
+				portionPrices = food.Portion_prices
+			}
+
+			if price == nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": "Price is required"})
+				return
+			}
+			if err := validateFoodPrices(*price, portionPrices); err != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			if food.Price != nil {
+				updateObj = append(updateObj, bson.E{"price", food.Price})
+			}
+			if food.Portion_prices != nil {
+				updateObj = append(updateObj, bson.E{"portion_prices", food.Portion_prices})
+			}
 		}
 
 		if food.Food_image != nil {
@@ -149,24 +213,70 @@ func UpdateFood() gin.HandlerFunc {
 
 		if food.Menu_id != nil {
 			err := menuCollection.FindOne(c, bson.M{"menu_id": food.Menu_id}).Decode(&menu)
-			defer cancel()
 			if err != nil {
 				msg := fmt.Sprintf("message:Menu does not Exist")
 				ctx.JSON(http.StatusInternalServerError, gin.H{"error": msg})
 				return
 			}
 
-			updateObj = append(updateObj, bson.E{"menu", food.Price})
+			updateObj = append(updateObj, bson.E{"menu_id", food.Menu_id})
+		}
+
+		if food.Recipe != nil || food.Portion_recipes != nil {
+			if validationErr := validate.StructPartial(food, "Recipe", "Portion_recipes"); validationErr != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+				return
+			}
+			if err := validateRecipes(c, food.Recipe, food.Portion_recipes); err != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if food.Recipe != nil {
+				updateObj = append(updateObj, bson.E{"recipe", food.Recipe})
+			}
+			if food.Portion_recipes != nil {
+				updateObj = append(updateObj, bson.E{"portion_recipes", food.Portion_recipes})
+			}
+		}
+
+		if food.Station != nil {
+			if validationErr := validate.StructPartial(food, "Station"); validationErr != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+				return
+			}
+			updateObj = append(updateObj, bson.E{"station", food.Station})
+		}
+
+		if food.Modifier_groups != nil {
+			if validationErr := validate.StructPartial(food, "Modifier_groups"); validationErr != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+				return
+			}
+
+			price := food.Price
+			if price == nil {
+				var foundFood models.Food
+				if err := foodCollection.FindOne(c, bson.M{"food_id": foodId}).Decode(&foundFood); err != nil {
+					ctx.JSON(http.StatusNotFound, gin.H{"error": "Food Item was not found"})
+					return
+				}
+				price = foundFood.Price
+			}
+			if price == nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": "Price is required"})
+				return
+			}
+			if err := validateModifierGroups(food.Modifier_groups, *price); err != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			updateObj = append(updateObj, bson.E{"modifier_groups", food.Modifier_groups})
 		}
 
 		food.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		updateObj = append(updateObj, bson.E{"updated_at", food.Updated_at})
 
-		upsert := true
 		filter := bson.M{"food_id": foodId}
-		opt := options.UpdateOptions{
-			Upsert: &upsert,
-		}
 
 		result, err := foodCollection.UpdateOne(
 			c,
@@ -174,7 +284,6 @@ func UpdateFood() gin.HandlerFunc {
 			bson.D{
 				{"$set", updateObj},
 			},
-			&opt,
 		)
 
 		if err != nil {
@@ -188,10 +297,305 @@ func UpdateFood() gin.HandlerFunc {
 	}
 }
 
-func ToFixed(num float64, precision int) float64 {
-	output := math.Pow(10, float64(precision))
-	return float64(Round(num*output)) / output
+// foodUnitPrice is the price of one portion of the food. Portions without a
+// configured price fall back to the food's base price.
+func foodUnitPrice(food models.Food, portion string) (models.Money, error) {
+	if price, ok := food.Portion_prices[portion]; ok {
+		return price, nil
+	}
+
+	if food.Price == nil {
+		return models.Money{}, fmt.Errorf("%s has no price", foodName(food))
+	}
+
+	return *food.Price, nil
 }
-func Round(num float64) int {
-	return int(num + math.Copysign(0.5, num))
+
+// modifiedUnitPrice is the price of one portion of the food with the picked
+// modifiers, which it returns checked and priced.
+func modifiedUnitPrice(food models.Food, portion string, selected []models.SelectedModifier) (models.Money, []models.SelectedModifier, error) {
+	price, err := foodUnitPrice(food, portion)
+	if err != nil {
+		return price, nil, err
+	}
+
+	modifiers, delta, err := selectModifiers(food, selected)
+	if err != nil {
+		return price, nil, err
+	}
+
+	price = price.Add(delta)
+	if price.IsNegative() {
+		return price, nil, fmt.Errorf("Modifiers take the price of %s below zero", foodName(food))
+	}
+
+	return price, modifiers, nil
+}
+
+// validateModifierGroups checks the modifier groups of a food and gives
+// every group and option without an id one. Price deltas must be in the
+// currency of the food's price.
+func validateModifierGroups(groups []models.ModifierGroup, price models.Money) error {
+	groupIds := map[string]bool{}
+	for i := range groups {
+		group := &groups[i]
+		if group.Group_id == "" {
+			group.Group_id = primitive.NewObjectID().Hex()
+		}
+		if groupIds[group.Group_id] {
+			return fmt.Errorf("Modifier group %s is on the food more than once", group.Group_id)
+		}
+		groupIds[group.Group_id] = true
+
+		if group.Required && group.Min_select < 1 {
+			group.Min_select = 1
+		}
+		if group.Min_select > group.Max_select || group.Max_select > len(group.Options) {
+			return fmt.Errorf("Modifier group %s must allow between min_select and max_select of its %d options", group.Name, len(group.Options))
+		}
+
+		optionIds := map[string]bool{}
+		for j := range group.Options {
+			option := &group.Options[j]
+			if option.Option_id == "" {
+				option.Option_id = primitive.NewObjectID().Hex()
+			}
+			if optionIds[option.Option_id] {
+				return fmt.Errorf("Option %s is in modifier group %s more than once", option.Option_id, group.Name)
+			}
+			optionIds[option.Option_id] = true
+
+			if !option.Price_delta.IsZero() && option.Price_delta.Currency != price.Currency {
+				return fmt.Errorf("Option %s of modifier group %s must be priced in %s", option.Name, group.Name, price.Currency)
+			}
+		}
+	}
+
+	return nil
+}
+
+// selectModifiers checks the modifiers picked for an order item against the
+// modifier groups of its food. It returns them with their names and prices,
+// and what they add to the unit price.
+func selectModifiers(food models.Food, selected []models.SelectedModifier) ([]models.SelectedModifier, models.Money, error) {
+	var delta models.Money
+
+	options := map[string]map[string]models.ModifierOption{}
+	for _, group := range food.Modifier_groups {
+		options[group.Group_id] = map[string]models.ModifierOption{}
+		for _, option := range group.Options {
+			options[group.Group_id][option.Option_id] = option
+		}
+	}
+
+	counts := map[string]int{}
+	picked := map[string]bool{}
+	modifiers := make([]models.SelectedModifier, 0, len(selected))
+	for _, modifier := range selected {
+		groupOptions, ok := options[modifier.Group_id]
+		if !ok {
+			return nil, delta, fmt.Errorf("%s has no modifier group %s", foodName(food), modifier.Group_id)
+		}
+		option, ok := groupOptions[modifier.Option_id]
+		if !ok {
+			return nil, delta, fmt.Errorf("Modifier group %s of %s has no option %s", modifier.Group_id, foodName(food), modifier.Option_id)
+		}
+		if picked[modifier.Group_id+"/"+modifier.Option_id] {
+			return nil, delta, fmt.Errorf("%s is picked more than once", option.Name)
+		}
+		picked[modifier.Group_id+"/"+modifier.Option_id] = true
+		counts[modifier.Group_id]++
+
+		delta = delta.Add(option.Price_delta)
+		modifiers = append(modifiers, models.SelectedModifier{
+			Group_id:    modifier.Group_id,
+			Option_id:   option.Option_id,
+			Name:        option.Name,
+			Price_delta: option.Price_delta,
+		})
+	}
+
+	for _, group := range food.Modifier_groups {
+		if counts[group.Group_id] < group.Min_select {
+			return nil, delta, fmt.Errorf("Pick at least %d of %s for %s", group.Min_select, group.Name, foodName(food))
+		}
+		if counts[group.Group_id] > group.Max_select {
+			return nil, delta, fmt.Errorf("Pick at most %d of %s for %s", group.Max_select, group.Name, foodName(food))
+		}
+	}
+
+	return modifiers, delta, nil
+}
+
+func modifierNames(modifiers []models.SelectedModifier) []string {
+	names := make([]string, 0, len(modifiers))
+	for _, modifier := range modifiers {
+		names = append(names, modifier.Name)
+	}
+
+	return names
+}
+
+// soldOutExpression works out in a pipeline whether a food is sold out, the
+// same way foodIsSoldOut does.
+var soldOutExpression = bson.D{{"$or", bson.A{
+	bson.D{{"$eq", bson.A{"$sold_out", true}}},
+	bson.D{{"$lte", bson.A{bson.D{{"$ifNull", bson.A{"$portions_left", 1}}}, 0}}},
+}}}
+
+func foodIsSoldOut(food models.Food) bool {
+	return (food.Sold_out != nil && *food.Sold_out) || (food.Portions_left != nil && *food.Portions_left <= 0)
+}
+
+// FoodAvailability 86's a food or brings it back. Portions_left starts
+// counting down the portions still to sell, Unlimited stops counting.
+type FoodAvailability struct {
+	Sold_out      *bool `json:"sold_out"`
+	Portions_left *int  `json:"portions_left" validate:"omitempty,min=0"`
+	Unlimited     bool  `json:"unlimited"`
+}
+
+// UpdateFoodAvailability lets the kitchen mark a food sold out or set how
+// many portions are left.
+func UpdateFoodAvailability() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var availability FoodAvailability
+		foodId := ctx.Param("food_id")
+
+		if err := ctx.BindJSON(&availability); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if validationErr := validate.Struct(availability); validationErr != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+		if availability.Unlimited && availability.Portions_left != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Set portions_left or unlimited, not both"})
+			return
+		}
+
+		var updateObj primitive.D
+		update := bson.D{}
+
+		if availability.Sold_out != nil {
+			updateObj = append(updateObj, bson.E{"sold_out", availability.Sold_out})
+		}
+		if availability.Portions_left != nil {
+			updateObj = append(updateObj, bson.E{"portions_left", availability.Portions_left})
+		}
+		if availability.Unlimited {
+			update = append(update, bson.E{"$unset", bson.D{{"portions_left", ""}}})
+		}
+
+		updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj = append(updateObj, bson.E{"updated_at", updatedAt})
+		update = append(update, bson.E{"$set", updateObj})
+
+		var food models.Food
+		err := foodCollection.FindOneAndUpdate(
+			c,
+			bson.M{"food_id": foodId},
+			update,
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&food)
+		if err == mongo.ErrNoDocuments {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Food Item was not found"})
+			return
+		}
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Food availability update failed"})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"food_id": food.Food_id, "sold_out": foodIsSoldOut(food), "portions_left": food.Portions_left})
+	}
+}
+
+// soldOutError is returned when an order wants more portions of a food than
+// are left.
+type soldOutError struct {
+	name string
+	left int
+}
+
+func (e soldOutError) Error() string {
+	if e.left <= 0 {
+		return fmt.Sprintf("%s is sold out", e.name)
+	}
+
+	return fmt.Sprintf("Only %d portions of %s are left", e.left, e.name)
+}
+
+// takePortions counts portions of foods off as they are ordered, counts
+// holds the portions ordered by food id. Foods that are not counted are
+// skipped.
+func takePortions(c context.Context, foods map[string]models.Food, counts map[string]int) error {
+	for foodId, count := range counts {
+		food := foods[foodId]
+		if food.Portions_left == nil {
+			continue
+		}
+
+		result, err := foodCollection.UpdateOne(
+			c,
+			bson.M{"food_id": foodId, "portions_left": bson.M{"$gte": count}},
+			bson.D{
+				{"$inc", bson.D{{"portions_left", -count}}},
+			},
+		)
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			var current models.Food
+			if err := foodCollection.FindOne(c, bson.M{"food_id": foodId}).Decode(&current); err != nil {
+				return err
+			}
+			left := 0
+			if current.Portions_left != nil {
+				left = *current.Portions_left
+			}
+			return soldOutError{name: foodName(food), left: left}
+		}
+	}
+
+	return nil
+}
+
+// returnPortions puts portions back on the count of a food, when an order
+// item is changed or voided.
+func returnPortions(c context.Context, foodId string, count int) error {
+	_, err := foodCollection.UpdateOne(
+		c,
+		bson.M{"food_id": foodId, "portions_left": bson.M{"$exists": true}},
+		bson.D{
+			{"$inc", bson.D{{"portions_left", count}}},
+		},
+	)
+
+	return err
+}
+
+// validateFoodPrices checks that every price of a food is positive and in
+// the same currency, so any portion can be billed together with the others.
+func validateFoodPrices(price models.Money, portionPrices map[string]models.Money) error {
+	if !price.IsPositive() {
+		return fmt.Errorf("Price must be greater than zero")
+	}
+
+	for portion, portionPrice := range portionPrices {
+		if !portionPrice.IsPositive() {
+			return fmt.Errorf("Price of portion %s must be greater than zero", portion)
+		}
+		if portionPrice.Currency != price.Currency {
+			return fmt.Errorf("Price of portion %s must be in %s", portion, price.Currency)
+		}
+	}
+
+	return nil
 }
@@ -0,0 +1,522 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/database"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var ingredientCollection *mongo.Collection = database.OpenCollection(database.Client, "ingredient")
+
+// GetInventory lists every ingredient with its stock level and a low_stock
+// flag. ?low_stock=true only lists the ones that are low.
+func GetInventory() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		lowStockStage := bson.D{{"$addFields", bson.D{
+			{"low_stock", bson.D{{"$lte", bson.A{"$on_hand", bson.D{{"$ifNull", bson.A{"$reorder_level", 0}}}}}}},
+		}}}
+		pipeline := mongo.Pipeline{lowStockStage}
+		if ctx.Query("low_stock") == "true" {
+			pipeline = append(pipeline, bson.D{{"$match", bson.D{{"low_stock", true}}}})
+		}
+		pipeline = append(pipeline, bson.D{{"$sort", bson.D{{"name", 1}}}})
+
+		result, err := ingredientCollection.Aggregate(c, pipeline)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while listing the Inventory"})
+			return
+		}
+
+		var inventory []bson.M
+		if err = result.All(c, &inventory); err != nil {
+			log.Println(err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while listing the Inventory"})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, inventory)
+	}
+}
+
+func GetIngredient() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var ingredient models.Ingredient
+		if err := ingredientCollection.FindOne(c, bson.M{"ingredient_id": ctx.Param("ingredient_id")}).Decode(&ingredient); err != nil {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Ingredient was not found"})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, ingredient)
+	}
+}
+
+func CreateIngredient() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var ingredient models.Ingredient
+
+		if err := ctx.BindJSON(&ingredient); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validationErr := validate.Struct(ingredient)
+		if validationErr != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		if ingredient.Supplier_id != nil {
+			if err := checkSupplier(c, *ingredient.Supplier_id); err != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		ingredient.Last_cost = nil
+
+		ingredient.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		ingredient.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		ingredient.ID = primitive.NewObjectID()
+		ingredient.Ingredient_id = ingredient.ID.Hex()
+
+		result, insertErr := ingredientCollection.InsertOne(c, ingredient)
+		if insertErr != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Ingredient was not created"})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, result)
+	}
+}
+
+// UpdateIngredient sets the details of an ingredient. Setting on_hand is a
+// stock count and overwrites the level, adjust_by moves it by a delta so it
+// does not race with sales.
+func UpdateIngredient() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var ingredient struct {
+			models.Ingredient
+			Adjust_by *float64 `json:"adjust_by"`
+		}
+		ingredientId := ctx.Param("ingredient_id")
+
+		if err := ctx.BindJSON(&ingredient); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var updateObj primitive.D
+
+		if ingredient.Name != nil {
+			if validationErr := validate.StructPartial(ingredient.Ingredient, "Name"); validationErr != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+				return
+			}
+			updateObj = append(updateObj, bson.E{"name", ingredient.Name})
+		}
+
+		if ingredient.Unit != nil {
+			if validationErr := validate.StructPartial(ingredient.Ingredient, "Unit"); validationErr != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+				return
+			}
+			updateObj = append(updateObj, bson.E{"unit", ingredient.Unit})
+		}
+
+		if ingredient.Reorder_level != nil {
+			if validationErr := validate.StructPartial(ingredient.Ingredient, "Reorder_level"); validationErr != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+				return
+			}
+			updateObj = append(updateObj, bson.E{"reorder_level", ingredient.Reorder_level})
+		}
+
+		if ingredient.Par_level != nil {
+			if validationErr := validate.StructPartial(ingredient.Ingredient, "Par_level"); validationErr != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+				return
+			}
+			updateObj = append(updateObj, bson.E{"par_level", ingredient.Par_level})
+		}
+
+		if ingredient.Supplier_id != nil {
+			if err := checkSupplier(c, *ingredient.Supplier_id); err != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			updateObj = append(updateObj, bson.E{"supplier_id", ingredient.Supplier_id})
+		}
+
+		if ingredient.On_hand != nil && ingredient.Adjust_by != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Set on_hand or adjust_by, not both"})
+			return
+		}
+		if ingredient.On_hand != nil {
+			updateObj = append(updateObj, bson.E{"on_hand", ingredient.On_hand})
+		}
+
+		ingredient.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj = append(updateObj, bson.E{"updated_at", ingredient.Updated_at})
+
+		update := bson.D{
+			{"$set", updateObj},
+		}
+		if ingredient.Adjust_by != nil {
+			update = append(update, bson.E{"$inc", bson.D{{"on_hand", *ingredient.Adjust_by}}})
+		}
+
+		result, err := ingredientCollection.UpdateOne(c, bson.M{"ingredient_id": ingredientId}, update)
+		if err != nil {
+			msg := fmt.Sprintf("Ingredient update failed")
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, result)
+	}
+}
+
+// ReorderSuggestion is how much of an ingredient to order from its
+// supplier. Daily_usage is what sales took from stock per day over the
+// report period, On_order is what open purchase orders still have to deliver.
+type ReorderSuggestion struct {
+	Ingredient_id      string        `json:"ingredient_id"`
+	Name               string        `json:"name"`
+	Unit               string        `json:"unit"`
+	Supplier_id        string        `json:"supplier_id"`
+	On_hand            float64       `json:"on_hand"`
+	On_order           float64       `json:"on_order"`
+	Daily_usage        float64       `json:"daily_usage"`
+	Reorder_level      float64       `json:"reorder_level"`
+	Par_level          float64       `json:"par_level"`
+	Lead_time_days     int           `json:"lead_time_days"`
+	Suggested_quantity float64       `json:"suggested_quantity"`
+	Estimated_cost     *models.Money `json:"estimated_cost"`
+}
+
+// GetReorderReport suggests what to order. Usage is averaged over the order
+// items of the last ?days= days, 14 by default. An ingredient is reordered
+// when, by the time its supplier can deliver, what is on hand and on order
+// would be down to its reorder level, or its par level when it has none. It
+// is ordered back up to par, plus what will be used while waiting.
+func GetReorderReport() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		days, err := strconv.Atoi(ctx.DefaultQuery("days", "14"))
+		if err != nil || days < 1 || days > 365 {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "days must be between 1 and 365"})
+			return
+		}
+
+		since := time.Now().AddDate(0, 0, -days)
+		usage, err := stockUsedSince(c, since)
+		if err != nil {
+			log.Println(err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while working out stock usage"})
+			return
+		}
+		onOrder, err := stockOnOrder(c)
+		if err != nil {
+			log.Println(err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while listing open Purchase Orders"})
+			return
+		}
+
+		var ingredients []models.Ingredient
+		result, err := ingredientCollection.Find(c, bson.M{})
+		if err == nil {
+			err = result.All(c, &ingredients)
+		}
+		if err != nil {
+			log.Println(err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while listing the Inventory"})
+			return
+		}
+
+		var suppliers []models.Supplier
+		result, err = supplierCollection.Find(c, bson.M{})
+		if err == nil {
+			err = result.All(c, &suppliers)
+		}
+		if err != nil {
+			log.Println(err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while listing Suppliers"})
+			return
+		}
+		leadTimes := map[string]int{}
+		for _, supplier := range suppliers {
+			if supplier.Lead_time_days != nil {
+				leadTimes[supplier.Supplier_id] = *supplier.Lead_time_days
+			}
+		}
+
+		suggestions := []ReorderSuggestion{}
+		for _, ingredient := range ingredients {
+			suggestion, ok := reorderSuggestion(ingredient, usage[ingredient.Ingredient_id]/float64(days), onOrder[ingredient.Ingredient_id], leadTimes)
+			if ok {
+				suggestions = append(suggestions, suggestion)
+			}
+		}
+
+		sort.Slice(suggestions, func(i, j int) bool {
+			if suggestions[i].Supplier_id != suggestions[j].Supplier_id {
+				return suggestions[i].Supplier_id < suggestions[j].Supplier_id
+			}
+			return suggestions[i].Name < suggestions[j].Name
+		})
+
+		ctx.JSON(http.StatusOK, gin.H{"days": days, "suggestions": suggestions})
+	}
+}
+
+// reorderSuggestion works out how much of an ingredient to order, given how
+// much of it is used a day and already on order. It is false when enough is
+// on hand and on order to last until the supplier can deliver.
+func reorderSuggestion(ingredient models.Ingredient, dailyUsage float64, onOrder float64, leadTimes map[string]int) (ReorderSuggestion, bool) {
+	suggestion := ReorderSuggestion{
+		Ingredient_id: ingredient.Ingredient_id,
+		On_order:      onOrder,
+		Daily_usage:   dailyUsage,
+	}
+	if ingredient.Name != nil {
+		suggestion.Name = *ingredient.Name
+	}
+	if ingredient.Unit != nil {
+		suggestion.Unit = *ingredient.Unit
+	}
+	if ingredient.On_hand != nil {
+		suggestion.On_hand = *ingredient.On_hand
+	}
+	if ingredient.Reorder_level != nil {
+		suggestion.Reorder_level = *ingredient.Reorder_level
+	}
+	suggestion.Par_level = suggestion.Reorder_level
+	if ingredient.Par_level != nil {
+		suggestion.Par_level = *ingredient.Par_level
+	}
+	if ingredient.Reorder_level == nil {
+		suggestion.Reorder_level = suggestion.Par_level
+	}
+	if ingredient.Supplier_id != nil {
+		suggestion.Supplier_id = *ingredient.Supplier_id
+		suggestion.Lead_time_days = leadTimes[*ingredient.Supplier_id]
+	}
+
+	leadTimeUsage := suggestion.Daily_usage * float64(suggestion.Lead_time_days)
+	available := suggestion.On_hand + suggestion.On_order
+	if available-leadTimeUsage > suggestion.Reorder_level {
+		return suggestion, false
+	}
+	suggestion.Suggested_quantity = math.Ceil(suggestion.Par_level + leadTimeUsage - available)
+	if suggestion.Suggested_quantity <= 0 {
+		return suggestion, false
+	}
+	if ingredient.Last_cost != nil {
+		cost := ingredient.Last_cost.Times(suggestion.Suggested_quantity)
+		suggestion.Estimated_cost = &cost
+	}
+
+	return suggestion, true
+}
+
+// stockUsedSince totals, by ingredient id, the stock taken by order items
+// since a time. Voided items put their stock back and are not counted.
+func stockUsedSince(c context.Context, since time.Time) (map[string]float64, error) {
+	matchStage := bson.D{{"$match", bson.D{{"created_at", bson.D{{"$gte", since}}}, {"stock_used.0", bson.D{{"$exists", true}}}}}}
+	unwindStage := bson.D{{"$unwind", "$stock_used"}}
+	groupStage := bson.D{{"$group", bson.D{{"_id", "$stock_used.ingredient_id"}, {"quantity", bson.D{{"$sum", "$stock_used.quantity"}}}}}}
+
+	result, err := orderItemCollection.Aggregate(c, mongo.Pipeline{matchStage, unwindStage, groupStage})
+	if err != nil {
+		return nil, err
+	}
+
+	return ingredientTotals(c, result)
+}
+
+// stockOnOrder totals, by ingredient id, what placed purchase orders still
+// have to deliver.
+func stockOnOrder(c context.Context) (map[string]float64, error) {
+	matchStage := bson.D{{"$match", bson.D{{"status", bson.D{{"$in", bson.A{models.PurchaseOrderOrdered, models.PurchaseOrderPartiallyReceived}}}}}}}
+	unwindStage := bson.D{{"$unwind", "$lines"}}
+	groupStage := bson.D{{"$group", bson.D{
+		{"_id", "$lines.ingredient_id"},
+		{"quantity", bson.D{{"$sum", bson.D{{"$max", bson.A{bson.D{{"$subtract", bson.A{"$lines.quantity", "$lines.received"}}}, 0}}}}}},
+	}}}
+
+	result, err := purchaseOrderCollection.Aggregate(c, mongo.Pipeline{matchStage, unwindStage, groupStage})
+	if err != nil {
+		return nil, err
+	}
+
+	return ingredientTotals(c, result)
+}
+
+func ingredientTotals(c context.Context, result *mongo.Cursor) (map[string]float64, error) {
+	var rows []struct {
+		Ingredient_id string  `bson:"_id"`
+		Quantity      float64 `bson:"quantity"`
+	}
+	if err := result.All(c, &rows); err != nil {
+		return nil, err
+	}
+
+	totals := make(map[string]float64, len(rows))
+	for _, row := range rows {
+		totals[row.Ingredient_id] = row.Quantity
+	}
+
+	return totals, nil
+}
+
+// validateRecipes checks that every ingredient of a food's recipes exists.
+func validateRecipes(c context.Context, recipe []models.RecipeItem, portionRecipes map[string][]models.RecipeItem) error {
+	ingredientIds := map[string]bool{}
+	for _, item := range recipe {
+		ingredientIds[item.Ingredient_id] = true
+	}
+	for _, portionRecipe := range portionRecipes {
+		for _, item := range portionRecipe {
+			ingredientIds[item.Ingredient_id] = true
+		}
+	}
+
+	for ingredientId := range ingredientIds {
+		count, err := ingredientCollection.CountDocuments(c, bson.M{"ingredient_id": ingredientId})
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return fmt.Errorf("Ingredient %s does not exist", ingredientId)
+		}
+	}
+
+	return nil
+}
+
+// stockUsage is what quantity portions of a food take from stock.
+func stockUsage(food models.Food, portion string, quantity int) []models.RecipeItem {
+	recipe := food.Recipe
+	if portionRecipe, ok := food.Portion_recipes[portion]; ok {
+		recipe = portionRecipe
+	}
+
+	usage := make([]models.RecipeItem, 0, len(recipe))
+	for _, item := range recipe {
+		usage = append(usage, models.RecipeItem{Ingredient_id: item.Ingredient_id, Quantity: item.Quantity * float64(quantity)})
+	}
+
+	return usage
+}
+
+// adjustStock takes usage from stock, or puts it back when restore is set.
+func adjustStock(c context.Context, usage []models.RecipeItem, restore bool) error {
+	sign := -1.0
+	if restore {
+		sign = 1
+	}
+
+	updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	for _, item := range usage {
+		_, err := ingredientCollection.UpdateOne(
+			c,
+			bson.M{"ingredient_id": item.Ingredient_id},
+			bson.D{
+				{"$inc", bson.D{{"on_hand", sign * item.Quantity}}},
+				{"$set", bson.D{{"updated_at", updatedAt}}},
+			},
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// restoreOrderItemStock puts back what an order item took from stock and
+// the portions it counted off, when it is voided or its order is cancelled.
+// It is only done once, the item is marked restocked as it is restored.
+func restoreOrderItemStock(c context.Context, orderItemId string) error {
+	var orderItem models.OrderItem
+	err := orderItemCollection.FindOneAndUpdate(
+		c,
+		bson.M{"order_item_id": orderItemId, "restocked": bson.M{"$ne": true}},
+		bson.D{
+			{"$set", bson.D{{"restocked", true}}},
+			{"$unset", bson.D{{"stock_used", ""}}},
+			{"$inc", bson.D{{"version", 1}}},
+		},
+	).Decode(&orderItem)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	quantity := 1
+	if orderItem.Quantity != nil {
+		quantity = *orderItem.Quantity
+	}
+	if orderItem.Bundle_id != nil {
+		for _, component := range orderItem.Components {
+			if err := returnPortions(c, component.Food_id, quantity); err != nil {
+				return err
+			}
+		}
+	} else if orderItem.Food_id != nil {
+		if err := returnPortions(c, *orderItem.Food_id, quantity); err != nil {
+			return err
+		}
+	}
+
+	return adjustStock(c, orderItem.Stock_used, true)
+}
+
+// restoreOrderStock restores the stock of every item of an order that has
+// not been restored yet, voided items already have been.
+func restoreOrderStock(c context.Context, orderId string) error {
+	result, err := orderItemCollection.Find(c, bson.M{"order_id": orderId, "restocked": bson.M{"$ne": true}})
+	if err != nil {
+		return err
+	}
+
+	var orderItems []models.OrderItem
+	if err = result.All(c, &orderItems); err != nil {
+		return err
+	}
+
+	for _, orderItem := range orderItems {
+		if err := restoreOrderItemStock(c, orderItem.Order_item_id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,49 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/models"
+)
+
+func TestReorderSuggestion(t *testing.T) {
+	float := func(value float64) *float64 { return &value }
+	supplier := "supplier"
+	cost := models.NewMoney(250, "USD")
+	leadTimes := map[string]int{supplier: 3}
+
+	tests := []struct {
+		name       string
+		ingredient models.Ingredient
+		dailyUsage float64
+		onOrder    float64
+		want       float64
+		reorder    bool
+	}{
+		{"above the reorder level", models.Ingredient{On_hand: float(20), Reorder_level: float(10), Par_level: float(30)}, 0, 0, 0, false},
+		{"at the reorder level", models.Ingredient{On_hand: float(10), Reorder_level: float(10), Par_level: float(30)}, 0, 0, 20, true},
+		{"stock on order counts", models.Ingredient{On_hand: float(5), Reorder_level: float(10), Par_level: float(30)}, 0, 10, 0, false},
+		{"usage until delivery", models.Ingredient{On_hand: float(20), Reorder_level: float(10), Par_level: float(30), Supplier_id: &supplier}, 4, 0, 22, true},
+		{"rounded up", models.Ingredient{On_hand: float(2.5), Reorder_level: float(5), Par_level: float(10)}, 0, 0, 8, true},
+		{"par level alone is the reorder level", models.Ingredient{On_hand: float(8), Par_level: float(10)}, 0, 0, 2, true},
+		{"reorder level alone is the par level", models.Ingredient{On_hand: float(4), Reorder_level: float(5)}, 0, 0, 1, true},
+		{"no levels and nothing left", models.Ingredient{On_hand: float(0)}, 0, 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		suggestion, ok := reorderSuggestion(tt.ingredient, tt.dailyUsage, tt.onOrder, leadTimes)
+		if ok != tt.reorder {
+			t.Errorf("%s: reorder = %v, want %v", tt.name, ok, tt.reorder)
+			continue
+		}
+		if ok && suggestion.Suggested_quantity != tt.want {
+			t.Errorf("%s: suggested %v, want %v", tt.name, suggestion.Suggested_quantity, tt.want)
+		}
+	}
+
+	ingredient := models.Ingredient{On_hand: float(0), Par_level: float(4), Last_cost: &cost}
+	suggestion, ok := reorderSuggestion(ingredient, 0, 0, leadTimes)
+	if !ok || suggestion.Estimated_cost == nil || *suggestion.Estimated_cost != models.NewMoney(1000, "USD") {
+		t.Errorf("estimated cost = %v, want 10.00 USD", suggestion.Estimated_cost)
+	}
+}
@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -9,42 +10,57 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/kwamekyeimonies/restaurant_management_system_backend/database"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/helpers"
 	"github.com/kwamekyeimonies/restaurant_management_system_backend/models"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 var invoiceCollection *mongo.Collection = database.OpenCollection(database.Client, "invoice")
 
+var errAlreadyBilled = errors.New("order item has already been billed")
+
 type InvoiceViewFormat struct {
-	Invoice_id       string
-	Order_id         string
-	Payment_method   string
-	Payment_status   *string
-	Payment_due_date time.Time
-	Table_number     interface{}
-	Order_details    interface{}
-	Payment_due      interface{}
+	Invoice_id          string
+	Order_id            string
+	Payment_method      string
+	Payment_status      *string
+	Payment_due_date    time.Time
+	Table_number        interface{}
+	Order_details       interface{}
+	Lines               []models.InvoiceLine
+	Subtotal            models.Money
+	Discount_total      models.Money
+	Tax_total           models.Money
+	Service_charge_rate *float64
+	Service_charge      models.Money
+	Tip                 *models.Money
+	Grand_total         models.Money
+	Amount_paid         models.Money
+	Amount_refunded     models.Money
+	Balance_due         models.Money
+	Payment_due         interface{}
 }
 
 func GetInvoices() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
-
-		result, err := invoiceCollection.Find(context.TODO(), bson.M{})
 		defer cancel()
+
+		result, err := invoiceCollection.Find(c, bson.M{})
 		if err != nil {
 			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while lising Invoice Items"})
-			//return
+			return
 		}
 
 		var allInvoices []bson.M
 		if err = result.All(c, &allInvoices); err != nil {
-			log.Fatal(err)
+			log.Println(err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while lising Invoice Items"})
+			return
 		}
-		ctx.JSON(http.StatusOK, result)
+		ctx.JSON(http.StatusOK, allInvoices)
 
 	}
 }
@@ -52,20 +68,24 @@ func GetInvoices() gin.HandlerFunc {
 func GetInvoice() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
 		invoiceId := ctx.Param("invoice_id")
 
 		var invoice models.Invoice
 
 		err := invoiceCollection.FindOne(c, bson.M{"invoice_id": invoiceId}).Decode(&invoice)
-		defer cancel()
 		if err != nil {
 			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error Occured while getting Invoice Item"})
-			//return
+			return
 		}
 
 		var invoiceView InvoiceViewFormat
 
 		allOrderedItems, err := ItemsByOrder(invoice.Order_id)
+		if err != nil || len(allOrderedItems) == 0 {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error Occured while listing the Order Items of the Invoice"})
+			return
+		}
 		invoiceView.Order_id = invoice.Order_id
 		invoiceView.Payment_due_date = invoice.Payment_due_date
 		invoiceView.Payment_method = "null"
@@ -79,6 +99,23 @@ func GetInvoice() gin.HandlerFunc {
 		invoiceView.Table_number = allOrderedItems[0]["table_number"]
 		invoiceView.Order_details = allOrderedItems[0]["order_items"]
 
+		// Invoices created before totals were stored fall back to the live
+		// order total.
+		if len(invoice.Lines) > 0 {
+			invoiceView.Lines = invoice.Lines
+			invoiceView.Subtotal = invoice.Subtotal
+			invoiceView.Discount_total = invoice.Discount_total
+			invoiceView.Tax_total = invoice.Tax_total
+			invoiceView.Service_charge_rate = invoice.Service_charge_rate
+			invoiceView.Service_charge = invoice.Service_charge
+			invoiceView.Tip = invoice.Tip
+			invoiceView.Grand_total = invoice.Grand_total
+			invoiceView.Amount_paid = invoice.Amount_paid
+			invoiceView.Amount_refunded = invoice.Amount_refunded
+			invoiceView.Balance_due = invoice.Balance_due
+			invoiceView.Payment_due = invoice.Balance_due
+		}
+
 		ctx.JSON(http.StatusOK, invoiceView)
 	}
 }
@@ -87,6 +124,7 @@ func CreateInvoice() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 
 		var c, cancel = context.WithTimeout(context.TODO(), 100*time.Second)
+		defer cancel()
 		var invoice models.Invoice
 
 		if err := ctx.BindJSON(&invoice); err != nil {
@@ -97,18 +135,16 @@ func CreateInvoice() gin.HandlerFunc {
 		var order models.Order
 
 		err := orderCollection.FindOne(c, bson.M{"order_id": invoice.Order_id}).Decode(&order)
-		defer cancel()
-
 		if err != nil {
 			msg := fmt.Sprintf("Order Unavailable....")
 			ctx.JSON(http.StatusInternalServerError, gin.H{"error": msg})
 			return
 		}
 
-		status := "PENDING"
-		if invoice.Payment_status == nil {
-			invoice.Payment_status = &status
-		}
+		status := models.PaymentPending
+		invoice.Payment_status = &status
+		invoice.Amount_paid = models.Money{}
+		invoice.Amount_refunded = models.Money{}
 		invoice.Payment_due_date, _ = time.Parse(time.RFC3339, time.Now().AddDate(0, 0, 1).Format(time.RFC3339))
 		invoice.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		invoice.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
@@ -121,14 +157,46 @@ func CreateInvoice() gin.HandlerFunc {
 			return
 		}
 
-		result, insertErr := invoiceCollection.InsertOne(c, invoice)
-		if insertErr != nil {
+		orderItems, err := unbilledOrderItems(c, invoice.Order_id)
+		if err != nil {
+			log.Println(err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while listing the Order items"})
+			return
+		}
+		if len(orderItems) == 0 {
+			ctx.JSON(http.StatusConflict, gin.H{"error": "Every item of this Order has already been billed"})
+			return
+		}
+
+		invoice.Lines, err = buildInvoiceLines(c, orderItems)
+		if err != nil {
+			log.Println(err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while pricing the Order"})
+			return
+		}
+
+		if err := totalInvoice(&invoice); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		err = insertInvoices(c, []models.Invoice{invoice}, map[string][]models.OrderItem{invoice.Invoice_id: orderItems})
+		if err == errAlreadyBilled {
+			ctx.JSON(http.StatusConflict, gin.H{"error": "Items of this Order were billed by someone else, please retry"})
+			return
+		}
+		if err != nil {
+			log.Println(err)
 			msg := fmt.Sprintf("Invoice of Item never existed.....")
 			ctx.JSON(http.StatusInternalServerError, gin.H{"error": msg})
 			return
 		}
 
-		ctx.JSON(http.StatusOK, result)
+		if err := setTableStatus(c, order.Table_id, models.TableAwaitingBill, ""); err != nil {
+			log.Println(err)
+		}
+
+		ctx.JSON(http.StatusOK, &mongo.InsertOneResult{InsertedID: invoice.ID})
 
 	}
 }
@@ -136,7 +204,9 @@ func CreateInvoice() gin.HandlerFunc {
 func UpdateInvoice() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		var c, cancel = context.WithTimeout(context.TODO(), 100*time.Second)
+		defer cancel()
 		var invoice models.Invoice
+		var foundInvoice models.Invoice
 		invoiceId := ctx.Param("invoice_id")
 
 		if err := ctx.BindJSON(&invoice); err != nil {
@@ -145,45 +215,475 @@ func UpdateInvoice() gin.HandlerFunc {
 		}
 
 		filter := bson.M{"invoice_id": invoiceId}
+		if err := invoiceCollection.FindOne(c, filter).Decode(&foundInvoice); err != nil {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Invoice was not found"})
+			return
+		}
+
 		var updateObj primitive.D
 
 		if invoice.Payment_method != nil {
+			if validationErr := validate.StructPartial(invoice, "Payment_method"); validationErr != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+				return
+			}
 			updateObj = append(updateObj, bson.E{"payment_method", invoice.Payment_method})
 		}
 
 		if invoice.Payment_status != nil {
+			if err := helpers.CheckUserRole(ctx, models.RoleCashier); err != nil {
+				ctx.JSON(http.StatusForbidden, gin.H{"error": "Only cashiers can change the payment status"})
+				return
+			}
+			if validationErr := validate.StructPartial(invoice, "Payment_status"); validationErr != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+				return
+			}
+			// Only an unsettled invoice with nothing left to pay, such as a
+			// comped one, is settled by hand. Everything else is settled by
+			// payments, and refunded invoices stay refunded.
+			current := models.PaymentPending
+			if foundInvoice.Payment_status != nil {
+				current = *foundInvoice.Payment_status
+			}
+			if *invoice.Payment_status != models.PaymentPaid || invoiceBalance(foundInvoice).IsPositive() ||
+				(current != models.PaymentPending && current != models.PaymentPartiallyPaid) {
+				ctx.JSON(http.StatusConflict, gin.H{"error": "Payment status follows the payments recorded against the Invoice"})
+				return
+			}
 			updateObj = append(updateObj, bson.E{"payment_status", invoice.Payment_status})
 		}
 
+		// Adjustments are re-totalled against the lines snapshotted when the
+		// invoice was created, later price changes never leak into a bill.
+		if invoice.Line_discounts != nil || invoice.Order_discount != nil || invoice.Service_charge_rate != nil || invoice.Tip != nil {
+			if foundInvoice.Split != nil && foundInvoice.Split.Type == models.SplitEven {
+				ctx.JSON(http.StatusConflict, gin.H{"error": "An even split can not be adjusted, adjust the bill before splitting it"})
+				return
+			}
+			if foundInvoice.Payment_status != nil && *foundInvoice.Payment_status != models.PaymentPending {
+				ctx.JSON(http.StatusConflict, gin.H{"error": "Only pending Invoices can be adjusted"})
+				return
+			}
+			if validationErr := validate.StructPartial(invoice, "Line_discounts", "Service_charge_rate"); validationErr != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+				return
+			}
+
+			if invoice.Line_discounts != nil {
+				foundInvoice.Line_discounts = invoice.Line_discounts
+			}
+			if invoice.Order_discount != nil {
+				foundInvoice.Order_discount = invoice.Order_discount
+			}
+			if invoice.Service_charge_rate != nil {
+				foundInvoice.Service_charge_rate = invoice.Service_charge_rate
+			}
+			if invoice.Tip != nil {
+				foundInvoice.Tip = invoice.Tip
+			}
+
+			if err := totalInvoice(&foundInvoice); err != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			updateObj = append(updateObj,
+				bson.E{"lines", foundInvoice.Lines},
+				bson.E{"line_discounts", foundInvoice.Line_discounts},
+				bson.E{"order_discount", foundInvoice.Order_discount},
+				bson.E{"service_charge_rate", foundInvoice.Service_charge_rate},
+				bson.E{"tip", foundInvoice.Tip},
+				bson.E{"subtotal", foundInvoice.Subtotal},
+				bson.E{"discount_total", foundInvoice.Discount_total},
+				bson.E{"tax_total", foundInvoice.Tax_total},
+				bson.E{"service_charge", foundInvoice.Service_charge},
+				bson.E{"grand_total", foundInvoice.Grand_total},
+				bson.E{"balance_due", foundInvoice.Balance_due},
+			)
+		}
+
 		invoice.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-		updateObj = append(updateObj, bson.E{"update_at", invoice.Updated_at})
+		updateObj = append(updateObj, bson.E{"updated_at", invoice.Updated_at})
 
-		upsert := true
-		opt := options.UpdateOptions{
-			Upsert: &upsert,
+		session, err := database.Client.StartSession()
+		if err != nil {
+			log.Println(err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to Update Item"})
+			return
 		}
-
-		status := "PENDING"
-		if invoice.Payment_status == nil {
-			invoice.Payment_status = &status
+		defer session.EndSession(c)
+
+		// The update only applies if no payment, refund or other update
+		// changed the invoice since it was read. Settling an invoice by hand
+		// closes its order in the same transaction, the invoice is not marked
+		// paid if that fails.
+		updateFilter := invoiceMoneyFilter(foundInvoice)
+		updateFilter["payment_status"] = foundInvoice.Payment_status
+		result, err := session.WithTransaction(c, func(sc mongo.SessionContext) (interface{}, error) {
+			result, err := invoiceCollection.UpdateOne(
+				sc,
+				updateFilter,
+				bson.D{
+					{"$set", updateObj},
+				},
+			)
+			if err != nil {
+				return nil, err
+			}
+			if result.MatchedCount == 0 {
+				return nil, errInvoiceChanged
+			}
+
+			if invoice.Payment_status != nil && *invoice.Payment_status == models.PaymentPaid {
+				if err := onInvoicePaid(sc, foundInvoice, ctx.GetString("user_id")); err != nil {
+					return nil, err
+				}
+			}
+
+			return result, nil
+		})
+
+		if err == errInvoiceChanged {
+			ctx.JSON(http.StatusConflict, gin.H{"error": "Invoice was changed by someone else, please retry"})
+			return
 		}
-
-		result, err := invoiceCollection.UpdateOne(
-			c,
-			filter,
-			bson.D{
-				{"&set", updateObj},
-			},
-			&opt,
-		)
-
 		if err != nil {
+			log.Println(err)
 			msg := fmt.Sprintf("Unable to Update Item")
 			ctx.JSON(http.StatusInternalServerError, gin.H{"error": msg})
 			return
 		}
 
-		defer cancel()
 		ctx.JSON(http.StatusOK, result)
 	}
 }
+
+// onInvoicePaid closes the order once every item is billed and every
+// invoice of it is settled, and sends its table to be cleaned. It runs in
+// the transaction that settles the invoice, a failure rolls the payment back.
+func onInvoicePaid(c context.Context, invoice models.Invoice, userId string) error {
+	var order models.Order
+	if err := orderCollection.FindOne(c, bson.M{"order_id": invoice.Order_id}).Decode(&order); err != nil {
+		return err
+	}
+
+	unpaid, err := invoiceCollection.CountDocuments(c, bson.M{"order_id": invoice.Order_id, "payment_status": bson.M{"$nin": bson.A{models.PaymentPaid, models.PaymentPartiallyRefunded, models.PaymentRefunded}}})
+	if err != nil {
+		return err
+	}
+	unbilled, err := orderItemCollection.CountDocuments(c, bson.M{"order_id": invoice.Order_id, "bill_id": nil})
+	if err != nil {
+		return err
+	}
+	if unpaid > 0 || unbilled > 0 {
+		return nil
+	}
+
+	if err := setTableStatus(c, order.Table_id, models.TableCleaning, ""); err != nil {
+		return err
+	}
+	if orderStatus(order) != models.OrderPaid {
+		return transitionOrder(c, &order, models.OrderPaid, userId, "invoice paid")
+	}
+
+	return nil
+}
+
+// unbilledOrderItems lists the items of an order that are not on any
+// invoice yet.
+func unbilledOrderItems(c context.Context, orderId string) ([]models.OrderItem, error) {
+	result, err := orderItemCollection.Find(c, bson.M{"order_id": orderId, "bill_id": nil})
+	if err != nil {
+		return nil, err
+	}
+
+	var orderItems []models.OrderItem
+	if err = result.All(c, &orderItems); err != nil {
+		return nil, err
+	}
+
+	return orderItems, nil
+}
+
+// insertInvoices writes the invoices of a bill and marks the items each bill
+// id covers as billed, in one transaction. It fails with errAlreadyBilled if
+// any of the items was billed in the meantime.
+func insertInvoices(c context.Context, invoices []models.Invoice, billed map[string][]models.OrderItem) error {
+	session, err := database.Client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(c)
+
+	_, err = session.WithTransaction(c, func(sc mongo.SessionContext) (interface{}, error) {
+		for billId, orderItems := range billed {
+			orderItemIds := make(bson.A, 0, len(orderItems))
+			for _, orderItem := range orderItems {
+				orderItemIds = append(orderItemIds, orderItem.Order_item_id)
+			}
+
+			result, err := orderItemCollection.UpdateMany(
+				sc,
+				bson.M{"order_item_id": bson.M{"$in": orderItemIds}, "bill_id": nil},
+				bson.D{
+					{"$set", bson.D{{"bill_id", billId}}},
+					{"$inc", bson.D{{"version", 1}}},
+				},
+			)
+			if err != nil {
+				return nil, err
+			}
+			if result.ModifiedCount != int64(len(orderItems)) {
+				return nil, errAlreadyBilled
+			}
+		}
+
+		documents := make([]interface{}, 0, len(invoices))
+		for _, invoice := range invoices {
+			documents = append(documents, invoice)
+		}
+
+		return invoiceCollection.InsertMany(sc, documents)
+	})
+
+	return err
+}
+
+// buildInvoiceLines snapshots order items with the tax rate of their menu
+// category.
+func buildInvoiceLines(c context.Context, orderItems []models.OrderItem) ([]models.InvoiceLine, error) {
+	var err error
+	categories := map[string]string{}
+	rates := map[string]float64{}
+	lines := make([]models.InvoiceLine, 0, len(orderItems))
+	for _, orderItem := range orderItems {
+		var food models.Food
+		var components []string
+		if orderItem.Bundle_id != nil {
+			// A bundle is billed as one line, named and taxed as the bundle.
+			var bundle models.Bundle
+			if err := bundleCollection.FindOne(c, bson.M{"bundle_id": orderItem.Bundle_id}).Decode(&bundle); err != nil {
+				return nil, fmt.Errorf("bundle %s of order item %s: %w", *orderItem.Bundle_id, orderItem.Order_item_id, err)
+			}
+			food.Name, food.Menu_id = bundle.Name, bundle.Menu_id
+			components = componentNames(orderItem.Components)
+		} else if err := foodCollection.FindOne(c, bson.M{"food_id": orderItem.Food_id}).Decode(&food); err != nil {
+			return nil, fmt.Errorf("food %s of order item %s: %w", *orderItem.Food_id, orderItem.Order_item_id, err)
+		}
+
+		category, ok := categories[*food.Menu_id]
+		if !ok {
+			var menu models.Menu
+			if err := menuCollection.FindOne(c, bson.M{"menu_id": food.Menu_id}).Decode(&menu); err != nil {
+				return nil, fmt.Errorf("menu %s of order item %s: %w", *food.Menu_id, orderItem.Order_item_id, err)
+			}
+			category = menu.Category
+			categories[*food.Menu_id] = category
+		}
+
+		rate, ok := rates[category]
+		if !ok {
+			rate, err = taxRateFor(c, category)
+			if err != nil {
+				return nil, err
+			}
+			rates[category] = rate
+		}
+
+		quantity := 1
+		if orderItem.Quantity != nil {
+			quantity = *orderItem.Quantity
+		}
+
+		unitPrice := models.Money{}
+		if orderItem.Unit_price != nil {
+			unitPrice = *orderItem.Unit_price
+		} else if food.Price != nil {
+			unitPrice = *food.Price
+		}
+
+		lines = append(lines, models.InvoiceLine{
+			Order_item_id: orderItem.Order_item_id,
+			Food_id:       food.Food_id,
+			Name:          foodName(food),
+			Category:      category,
+			Quantity:      quantity,
+			Portion:       orderItemPortion(orderItem),
+			Modifiers:     modifierNames(orderItem.Modifiers),
+			Components:    components,
+			Unit_price:    unitPrice,
+			Tax_rate:      rate,
+		})
+	}
+
+	return lines, nil
+}
+
+// totalInvoice works out every computed amount of the invoice from its lines
+// and adjustments. The order discount is spread over the lines in proportion
+// to their value so each line is taxed on what was actually charged.
+func totalInvoice(invoice *models.Invoice) error {
+	currency := models.DefaultCurrency
+	if len(invoice.Lines) > 0 {
+		currency = invoice.Lines[0].Unit_price.Currency
+	}
+	for _, line := range invoice.Lines {
+		if line.Unit_price.Currency != currency {
+			return fmt.Errorf("Order items are priced in more than one currency")
+		}
+	}
+
+	for orderItemId, discount := range invoice.Line_discounts {
+		if !invoiceHasLine(*invoice, orderItemId) {
+			return fmt.Errorf("Order item %s is not on this Invoice", orderItemId)
+		}
+		if err := validateDiscount(discount, currency); err != nil {
+			return fmt.Errorf("Discount on order item %s: %w", orderItemId, err)
+		}
+	}
+	if invoice.Order_discount != nil {
+		if err := validateDiscount(*invoice.Order_discount, currency); err != nil {
+			return fmt.Errorf("Order discount: %w", err)
+		}
+	}
+	if invoice.Tip != nil && (invoice.Tip.IsNegative() || invoice.Tip.Currency != currency) {
+		return fmt.Errorf("Tip must be a positive amount in %s", currency)
+	}
+
+	subtotal := models.NewMoney(0, currency)
+	lineDiscounts := models.NewMoney(0, currency)
+	weights := make([]int64, len(invoice.Lines))
+	for i := range invoice.Lines {
+		line := &invoice.Lines[i]
+		line.Gross = line.Unit_price.Mul(int64(line.Quantity))
+		line.Discount_amount = models.NewMoney(0, currency)
+		if discount, ok := invoice.Line_discounts[line.Order_item_id]; ok {
+			line.Discount_amount = discountAmount(discount, line.Gross)
+		}
+		line.Net = line.Gross.Sub(line.Discount_amount)
+		if line.Voided {
+			line.Discount_amount = models.NewMoney(0, currency)
+			line.Net = models.NewMoney(0, currency)
+			line.Tax = models.NewMoney(0, currency)
+			continue
+		}
+
+		subtotal = subtotal.Add(line.Gross)
+		lineDiscounts = lineDiscounts.Add(line.Discount_amount)
+		weights[i] = line.Net.Amount
+	}
+
+	net := subtotal.Sub(lineDiscounts)
+	orderDiscount := models.NewMoney(0, currency)
+	if invoice.Order_discount != nil {
+		orderDiscount = discountAmount(*invoice.Order_discount, net)
+	}
+
+	taxTotal := models.NewMoney(0, currency)
+	shares := orderDiscount.Allocate(weights)
+	for i := range invoice.Lines {
+		line := &invoice.Lines[i]
+		line.Tax = line.Net.Sub(shares[i]).Percent(line.Tax_rate)
+		taxTotal = taxTotal.Add(line.Tax)
+	}
+
+	serviceCharge := models.NewMoney(0, currency)
+	if invoice.Service_charge_rate != nil {
+		serviceCharge = net.Sub(orderDiscount).Percent(*invoice.Service_charge_rate)
+	}
+
+	tip := models.NewMoney(0, currency)
+	if invoice.Tip != nil {
+		tip = *invoice.Tip
+	}
+
+	invoice.Subtotal = subtotal
+	invoice.Discount_total = lineDiscounts.Add(orderDiscount)
+	invoice.Tax_total = taxTotal
+	invoice.Service_charge = serviceCharge
+	invoice.Grand_total = subtotal.Sub(invoice.Discount_total).Add(taxTotal).Add(serviceCharge).Add(tip)
+	invoice.Balance_due = invoiceBalance(*invoice)
+
+	return nil
+}
+
+func validateDiscount(discount models.Discount, currency string) error {
+	if err := validate.Struct(discount); err != nil {
+		return err
+	}
+	if discount.Type == models.DiscountAmount && (discount.Amount.IsNegative() || discount.Amount.Currency != currency) {
+		return fmt.Errorf("amount must be a positive amount in %s", currency)
+	}
+
+	return nil
+}
+
+// discountAmount is what a discount takes off base, never more than base.
+func discountAmount(discount models.Discount, base models.Money) models.Money {
+	if discount.Type == models.DiscountPercent {
+		return base.Percent(discount.Percent).Min(base)
+	}
+
+	return discount.Amount.Min(base)
+}
+
+func invoiceHasLine(invoice models.Invoice, orderItemId string) bool {
+	for _, line := range invoice.Lines {
+		if line.Order_item_id == orderItemId {
+			return true
+		}
+	}
+
+	return false
+}
+
+// invoiceBalance is what is left to pay on an invoice after its payments.
+// Refunds give money back without asking for it again, they are reported in
+// Amount_refunded and never raise the balance. An invoice paid beyond its
+// total, such as after a void, owes nothing rather than a negative balance.
+func invoiceBalance(invoice models.Invoice) models.Money {
+	balance := invoice.Grand_total.Sub(invoice.Amount_paid)
+	if !balance.IsPositive() {
+		return models.NewMoney(0, balance.Currency)
+	}
+
+	return balance
+}
+
+// invoicePaymentStatus works the payment status out from the amounts paid
+// and refunded.
+func invoicePaymentStatus(invoice models.Invoice) string {
+	switch {
+	case invoice.Amount_refunded.IsPositive() && invoice.Amount_refunded.Amount >= invoice.Amount_paid.Amount:
+		return models.PaymentRefunded
+	case invoice.Amount_refunded.IsPositive():
+		return models.PaymentPartiallyRefunded
+	case invoice.Amount_paid.IsZero():
+		return models.PaymentPending
+	case invoiceBalance(invoice).IsPositive():
+		return models.PaymentPartiallyPaid
+	}
+
+	return models.PaymentPaid
+}
+
+// invoiceMoneyFilter matches the invoice only if no payment, refund or void
+// has changed its amounts since it was read. Older invoices may not have
+// these amounts at all.
+func invoiceMoneyFilter(invoice models.Invoice) bson.M {
+	filter := bson.M{"invoice_id": invoice.Invoice_id}
+	amounts := map[string]models.Money{
+		"grand_total.amount":     invoice.Grand_total,
+		"amount_paid.amount":     invoice.Amount_paid,
+		"amount_refunded.amount": invoice.Amount_refunded,
+	}
+	for field, amount := range amounts {
+		filter[field] = amount.Amount
+		if amount.IsZero() {
+			filter[field] = bson.M{"$in": bson.A{0, nil}}
+		}
+	}
+
+	return filter
+}
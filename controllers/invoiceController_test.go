@@ -0,0 +1,34 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/models"
+)
+
+func TestInvoiceBalance(t *testing.T) {
+	usd := func(amount int64) models.Money { return models.NewMoney(amount, "USD") }
+
+	tests := []struct {
+		name    string
+		invoice models.Invoice
+		balance int64
+		status  string
+	}{
+		{"nothing paid", models.Invoice{Grand_total: usd(100)}, 100, models.PaymentPending},
+		{"partly paid", models.Invoice{Grand_total: usd(100), Amount_paid: usd(40)}, 60, models.PaymentPartiallyPaid},
+		{"paid", models.Invoice{Grand_total: usd(100), Amount_paid: usd(100)}, 0, models.PaymentPaid},
+		{"refunds do not raise the balance", models.Invoice{Grand_total: usd(100), Amount_paid: usd(100), Amount_refunded: usd(100)}, 0, models.PaymentRefunded},
+		{"void refunding an overpayment", models.Invoice{Grand_total: usd(70), Amount_paid: usd(100), Amount_refunded: usd(30)}, 0, models.PaymentPartiallyRefunded},
+		{"overpaid", models.Invoice{Grand_total: usd(70), Amount_paid: usd(100)}, 0, models.PaymentPaid},
+	}
+
+	for _, tt := range tests {
+		if got := invoiceBalance(tt.invoice); got != usd(tt.balance) {
+			t.Errorf("%s: balance = %s, want %d", tt.name, got, tt.balance)
+		}
+		if got := invoicePaymentStatus(tt.invoice); got != tt.status {
+			t.Errorf("%s: status = %s, want %s", tt.name, got, tt.status)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package controllers
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// nextPrepStatus is the status an order item moves to when it is bumped.
+var nextPrepStatus = map[string]string{
+	models.PrepQueued:  models.PrepCooking,
+	models.PrepCooking: models.PrepReady,
+	models.PrepReady:   models.PrepServed,
+}
+
+// GetKitchenQueue lists the order items the kitchen still has to deal with,
+// oldest first.
+func GetKitchenQueue() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		filter := bson.M{"prep_status": bson.M{"$in": bson.A{models.PrepQueued, models.PrepCooking, models.PrepReady}}}
+		opts := options.Find().SetSort(bson.D{{"created_at", 1}})
+
+		result, err := orderItemCollection.Find(c, filter, opts)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while listing the Kitchen queue"})
+			return
+		}
+
+		var queue []bson.M
+		if err = result.All(c, &queue); err != nil {
+			log.Println(err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while listing the Kitchen queue"})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, queue)
+	}
+}
+
+// KitchenStream pushes every new or changed order item to the client as a
+// Server-Sent Event. It is backed by a MongoDB change stream, so every
+// server instance sees changes made by the others.
+func KitchenStream() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		c, cancel := context.WithCancel(ctx.Request.Context())
+		defer cancel()
+
+		pipeline := mongo.Pipeline{
+			bson.D{{"$match", bson.D{{"operationType", bson.D{{"$in", bson.A{"insert", "update", "replace"}}}}}}},
+		}
+		stream, err := orderItemCollection.Watch(c, pipeline, options.ChangeStream().SetFullDocument(options.UpdateLookup))
+		if err != nil {
+			log.Println(err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Kitchen stream is unavailable"})
+			return
+		}
+
+		// The change stream is only read by this goroutine. It is stopped and
+		// waited for before the stream is closed, a cursor must not be closed
+		// while Next is running on it.
+		events := make(chan models.OrderItem)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			defer close(events)
+			for stream.Next(c) {
+				var event struct {
+					FullDocument models.OrderItem `bson:"fullDocument"`
+				}
+				if err := stream.Decode(&event); err != nil {
+					log.Println(err)
+					continue
+				}
+
+				select {
+				case events <- event.FullDocument:
+				case <-c.Done():
+					return
+				}
+			}
+		}()
+
+		defer func() {
+			cancel()
+			<-done
+			stream.Close(context.Background())
+		}()
+
+		keepAlive := time.NewTicker(15 * time.Second)
+		defer keepAlive.Stop()
+
+		ctx.Stream(func(w io.Writer) bool {
+			select {
+			case orderItem, ok := <-events:
+				if !ok {
+					return false
+				}
+				ctx.SSEvent("order_item", orderItem)
+				return true
+			case <-keepAlive.C:
+				ctx.SSEvent("ping", time.Now().Unix())
+				return true
+			case <-c.Done():
+				return false
+			}
+		})
+	}
+}
+
+// BumpOrderItem advances an order item to its next prep status.
+func BumpOrderItem() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		orderItemId := ctx.Param("order_item_id")
+		var orderItem models.OrderItem
+
+		if err := orderItemCollection.FindOne(c, bson.M{"order_item_id": orderItemId}).Decode(&orderItem); err != nil {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Order Item was not found"})
+			return
+		}
+
+		current := models.PrepQueued
+		if orderItem.Prep_status != nil {
+			current = *orderItem.Prep_status
+		}
+
+		if current == models.PrepCancelled {
+			ctx.JSON(http.StatusConflict, gin.H{"error": "Order of this Item was cancelled"})
+			return
+		}
+		next, ok := nextPrepStatus[current]
+		if !ok {
+			ctx.JSON(http.StatusConflict, gin.H{"error": "Order Item has already been served"})
+			return
+		}
+
+		updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		result, err := orderItemCollection.UpdateOne(
+			c,
+			bson.M{"order_item_id": orderItemId, "prep_status": orderItem.Prep_status},
+			bson.D{
+				{"$set", bson.D{{"prep_status", next}, {"updated_at", updatedAt}}},
+				{"$inc", bson.D{{"version", 1}}},
+			},
+		)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Order Item bump failed"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			ctx.JSON(http.StatusConflict, gin.H{"error": "Order Item was bumped by someone else, please retry"})
+			return
+		}
+
+		orderItem.Prep_status = &next
+		orderItem.Updated_at = updatedAt
+
+		ctx.JSON(http.StatusOK, orderItem)
+	}
+}
+
+// cancelPrep takes the items of a cancelled order off the kitchen queue.
+// Items already served are left as they are.
+func cancelPrep(c context.Context, orderId string) error {
+	updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	_, err := orderItemCollection.UpdateMany(
+		c,
+		bson.M{"order_id": orderId, "prep_status": bson.M{"$in": bson.A{models.PrepQueued, models.PrepCooking, models.PrepReady}}},
+		bson.D{
+			{"$set", bson.D{{"prep_status", models.PrepCancelled}, {"updated_at", updatedAt}}},
+			{"$inc", bson.D{{"version", 1}}},
+		},
+	)
+
+	return err
+}
@@ -13,7 +13,6 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 var menuCollection *mongo.Collection = database.OpenCollection(database.Client, "menu")
@@ -53,9 +52,53 @@ func GetMenu() gin.HandlerFunc {
 	}
 }
 
+func GetActiveMenus() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		result, err := menuCollection.Find(c, bson.M{})
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured listing the active Menus"})
+			return
+		}
+
+		var allMenus []models.Menu
+		if err = result.All(c, &allMenus); err != nil {
+			log.Println(err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured listing the active Menus"})
+			return
+		}
+
+		now := time.Now()
+		activeMenus := []gin.H{}
+		for _, menu := range allMenus {
+			if !menuIsActive(menu, now) {
+				continue
+			}
+
+			var foods []models.Food
+			foodResult, err := foodCollection.Find(c, bson.M{"menu_id": menu.Menu_id})
+			if err == nil {
+				err = foodResult.All(c, &foods)
+			}
+			if err != nil {
+				log.Println(err)
+				ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured listing the active Menus"})
+				return
+			}
+
+			activeMenus = append(activeMenus, gin.H{"menu": menu, "food_items": foods})
+		}
+
+		ctx.JSON(http.StatusOK, activeMenus)
+	}
+}
+
 func CreateMenu() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
 		var menu models.Menu
 		if err := ctx.BindJSON(&menu); err != nil {
 			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -68,6 +111,11 @@ func CreateMenu() gin.HandlerFunc {
 			return
 		}
 
+		if menu.Start_Date != nil && menu.End_Date != nil && !menu.End_Date.After(*menu.Start_Date) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Menu end date must be after its start date"})
+			return
+		}
+
 		menu.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		menu.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		menu.ID = primitive.NewObjectID()
@@ -80,16 +128,16 @@ func CreateMenu() gin.HandlerFunc {
 			return
 		}
 
-		defer cancel()
 		ctx.JSON(http.StatusOK, result)
-		defer cancel()
 	}
 }
 
 func UpdateMenu() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
 		var menu models.Menu
+		var foundMenu models.Menu
 
 		if err := ctx.BindJSON(&menu); err != nil {
 			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -99,56 +147,175 @@ func UpdateMenu() gin.HandlerFunc {
 		menuId := ctx.Param("menu_id")
 		filter := bson.M{"menu_id": menuId}
 
+		if err := menuCollection.FindOne(c, filter).Decode(&foundMenu); err != nil {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Menu was not found"})
+			return
+		}
+
 		var updateObj primitive.D
 
-		if menu.Start_Date != nil && menu.End_Date != nil {
-			if !inTimeSpan(*menu.Start_Date, *menu.End_Date, time.Now()) {
-				msg := "Kindly check the Time typed"
-				ctx.JSON(http.StatusInternalServerError, gin.H{"error": msg})
-				defer cancel()
+		start, end := foundMenu.Start_Date, foundMenu.End_Date
+		if menu.Start_Date != nil {
+			start = menu.Start_Date
+			updateObj = append(updateObj, bson.E{"start_date", menu.Start_Date})
+		}
+		if menu.End_Date != nil {
+			end = menu.End_Date
+			updateObj = append(updateObj, bson.E{"end_date", menu.End_Date})
+		}
+		if start != nil && end != nil && !end.After(*start) {
+			msg := "Kindly check the Time typed"
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+
+		if menu.Availability != nil {
+			if validationErr := validate.StructPartial(menu, "Availability"); validationErr != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
 				return
 			}
+			updateObj = append(updateObj, bson.E{"availability", menu.Availability})
+		}
 
-			updateObj = append(updateObj, bson.E{"start_date", menu.Start_Date})
-			updateObj = append(updateObj, bson.E{"end-date", menu.End_Date})
+		if menu.Name != "" {
+			updateObj = append(updateObj, bson.E{"name", menu.Name})
+		}
 
-			if menu.Name != "" {
-				updateObj = append(updateObj, bson.E{"name", menu.Name})
-			}
+		if menu.Category != "" {
+			updateObj = append(updateObj, bson.E{"category", menu.Category})
+		}
 
-			if menu.Category != "" {
-				updateObj = append(updateObj, bson.E{"category", menu.Category})
-			}
+		menu.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj = append(updateObj, bson.E{"updated_at", menu.Updated_at})
 
-			menu.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-			updateObj = append(updateObj, bson.E{"updated_at", menu.Updated_at})
-			upsert := true
-			opt := options.UpdateOptions{
-				Upsert: &upsert,
-			}
+		result, err := menuCollection.UpdateOne(
+			c,
+			filter,
+			bson.D{
+				{"$set", updateObj},
+			},
+		)
 
-			result, err := menuCollection.UpdateOne(
-				c,
-				filter,
-				bson.D{
-					{"$set", updateObj},
-				},
-				&opt,
-			)
+		if err != nil {
+			msg := "Menu update failed"
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			return
+		}
 
-			if err != nil {
-				msg := "Menu update failed"
-				ctx.JSON(http.StatusInternalServerError, gin.H{"error": msg})
-			}
+		ctx.JSON(http.StatusOK, result)
+	}
+}
 
-			defer cancel()
-			ctx.JSON(http.StatusOK, result)
+var weekdays = map[time.Weekday]string{
+	time.Sunday:    "SUN",
+	time.Monday:    "MON",
+	time.Tuesday:   "TUE",
+	time.Wednesday: "WED",
+	time.Thursday:  "THU",
+	time.Friday:    "FRI",
+	time.Saturday:  "SAT",
+}
+
+// menuIsActive reports whether food from the menu can be ordered at the given
+// time. Windows are evaluated in the server's local time zone (set TZ).
+func menuIsActive(menu models.Menu, check time.Time) bool {
+	if menu.Start_Date != nil && check.Before(*menu.Start_Date) {
+		return false
+	}
+	if menu.End_Date != nil && check.After(*menu.End_Date) {
+		return false
+	}
+	if len(menu.Availability) == 0 {
+		return true
+	}
 
+	local := check.Local()
+	for _, window := range menu.Availability {
+		if windowIsOpen(window, local) {
+			return true
 		}
+	}
+
+	return false
+}
+
+func windowIsOpen(window models.MenuWindow, check time.Time) bool {
+	start, err := time.Parse("15:04", window.Start_time)
+	if err != nil {
+		return false
+	}
+	end, err := time.Parse("15:04", window.End_time)
+	if err != nil {
+		return false
+	}
+
+	minutes := check.Hour()*60 + check.Minute()
+	startMinutes := start.Hour()*60 + start.Minute()
+	endMinutes := end.Hour()*60 + end.Minute()
 
+	if startMinutes <= endMinutes {
+		return minutes >= startMinutes && minutes < endMinutes && windowHasDay(window, check.Weekday())
+	}
+
+	// The window runs past midnight, so the early morning belongs to the
+	// day before.
+	if minutes >= startMinutes {
+		return windowHasDay(window, check.Weekday())
+	}
+	if minutes < endMinutes {
+		return windowHasDay(window, (check.Weekday()+6)%7)
 	}
+
+	return false
+}
+
+func windowHasDay(window models.MenuWindow, day time.Weekday) bool {
+	if len(window.Days) == 0 {
+		return true
+	}
+
+	for _, d := range window.Days {
+		if d == weekdays[day] {
+			return true
+		}
+	}
+
+	return false
 }
 
-func inTimeSpan(start, end, check time.Time) bool {
-	return start.After(time.Now()) && end.After(start)
+// orderableFood loads a food item and checks that its menu is currently
+// available for ordering.
+func orderableFood(c context.Context, foodId string) (models.Food, error) {
+	var food models.Food
+	var menu models.Menu
+
+	if err := foodCollection.FindOne(c, bson.M{"food_id": foodId}).Decode(&food); err != nil {
+		return food, fmt.Errorf("Food %s was not found", foodId)
+	}
+
+	if food.Menu_id == nil {
+		return food, fmt.Errorf("Food %s is not on any menu", foodId)
+	}
+
+	if err := menuCollection.FindOne(c, bson.M{"menu_id": food.Menu_id}).Decode(&menu); err != nil {
+		return food, fmt.Errorf("Menu of food %s was not found", foodId)
+	}
+
+	if !menuIsActive(menu, time.Now()) {
+		return food, fmt.Errorf("%s is not available right now, the %s menu is closed", foodName(food), menu.Name)
+	}
+
+	if foodIsSoldOut(food) {
+		return food, soldOutError{name: foodName(food)}
+	}
+
+	return food, nil
+}
+
+func foodName(food models.Food) string {
+	if food.Name == nil {
+		return food.Food_id
+	}
+
+	return *food.Name
 }
@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/models"
+)
+
+func TestWindowIsOpen(t *testing.T) {
+	// 5 January 2024 is a Friday.
+	at := func(day int, hour int, minute int) time.Time {
+		return time.Date(2024, time.January, day, hour, minute, 0, 0, time.Local)
+	}
+	lunch := models.MenuWindow{Days: []string{"FRI"}, Start_time: "11:30", End_time: "15:00"}
+	lateFriday := models.MenuWindow{Days: []string{"FRI"}, Start_time: "22:00", End_time: "02:00"}
+	everyNight := models.MenuWindow{Start_time: "23:00", End_time: "01:00"}
+
+	tests := []struct {
+		name   string
+		window models.MenuWindow
+		check  time.Time
+		open   bool
+	}{
+		{"lunch opens on time", lunch, at(5, 11, 30), true},
+		{"lunch before opening", lunch, at(5, 11, 29), false},
+		{"lunch closes at the end time", lunch, at(5, 15, 0), false},
+		{"lunch on another day", lunch, at(6, 12, 0), false},
+		{"overnight before start", lateFriday, at(5, 21, 59), false},
+		{"overnight on its day", lateFriday, at(5, 23, 30), true},
+		{"overnight just before midnight", lateFriday, at(5, 23, 59), true},
+		{"overnight after midnight belongs to the day before", lateFriday, at(6, 1, 59), true},
+		{"overnight closes at the end time", lateFriday, at(6, 2, 0), false},
+		{"early morning of its own day is the night before", lateFriday, at(5, 1, 0), false},
+		{"overnight every day at midnight", everyNight, at(3, 0, 0), true},
+		{"overnight every day in the afternoon", everyNight, at(3, 14, 0), false},
+		{"bad start time", models.MenuWindow{Start_time: "25:00", End_time: "02:00"}, at(5, 1, 0), false},
+	}
+
+	for _, tt := range tests {
+		if got := windowIsOpen(tt.window, tt.check); got != tt.open {
+			t.Errorf("%s: windowIsOpen at %s = %v, want %v", tt.name, tt.check.Format("Mon 15:04"), got, tt.open)
+		}
+	}
+}
+
+func TestMenuIsActive(t *testing.T) {
+	start := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.Local)
+	end := time.Date(2024, time.January, 31, 0, 0, 0, 0, time.Local)
+	night := []models.MenuWindow{{Start_time: "22:00", End_time: "02:00"}}
+
+	tests := []struct {
+		name   string
+		menu   models.Menu
+		check  time.Time
+		active bool
+	}{
+		{"no dates or windows", models.Menu{}, start, true},
+		{"before the start date", models.Menu{Start_Date: &start}, start.Add(-time.Minute), false},
+		{"after the end date", models.Menu{End_Date: &end}, end.Add(time.Minute), false},
+		{"inside an overnight window", models.Menu{Availability: night}, start.Add(time.Hour), true},
+		{"outside every window", models.Menu{Availability: night}, start.Add(12 * time.Hour), false},
+	}
+
+	for _, tt := range tests {
+		if got := menuIsActive(tt.menu, tt.check); got != tt.active {
+			t.Errorf("%s: menuIsActive = %v, want %v", tt.name, got, tt.active)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/database"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/helpers"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var noteCollection *mongo.Collection = database.OpenCollection(database.Client, "note")
+
+// GetNotes lists notes oldest first, ?parent_type= and ?parent_id= list the
+// notes of one order, order item, table or customer.
+func GetNotes() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		filter := bson.M{}
+		if parentType := ctx.Query("parent_type"); parentType != "" {
+			filter["parent_type"] = parentType
+		}
+		if parentId := ctx.Query("parent_id"); parentId != "" {
+			filter["parent_id"] = parentId
+		}
+
+		result, err := noteCollection.Find(c, filter, options.Find().SetSort(bson.D{{"created_at", 1}}))
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while listing Notes"})
+			return
+		}
+
+		var allNotes []bson.M
+		if err = result.All(c, &allNotes); err != nil {
+			log.Println(err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while listing Notes"})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, allNotes)
+	}
+}
+
+func GetNote() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var note models.Note
+		if err := noteCollection.FindOne(c, bson.M{"note_id": ctx.Param("note_id")}).Decode(&note); err != nil {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Note was not found"})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, note)
+	}
+}
+
+func CreateNote() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var note models.Note
+
+		if err := ctx.BindJSON(&note); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validationErr := validate.Struct(note)
+		if validationErr != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		if err := checkNoteParent(c, *note.Parent_type, *note.Parent_id); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		note = newNote(*note.Parent_type, *note.Parent_id, note.Title, note.Text, ctx.GetString("user_id"))
+
+		result, insertErr := noteCollection.InsertOne(c, note)
+		if insertErr != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Note was not created"})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, result)
+	}
+}
+
+// UpdateNote changes the title or text of a note, it stays on the same
+// parent. Only its author or a manager may change it, the last editor is
+// kept in updated_by.
+func UpdateNote() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var note struct {
+			Title *string `json:"title" validate:"omitempty,max=100"`
+			Text  *string `json:"text" validate:"omitempty,min=1,max=500"`
+		}
+		noteId := ctx.Param("note_id")
+
+		if err := ctx.BindJSON(&note); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if validationErr := validate.Struct(note); validationErr != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		var foundNote models.Note
+		if err := noteCollection.FindOne(c, bson.M{"note_id": noteId}).Decode(&foundNote); err != nil {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Note was not found"})
+			return
+		}
+		if err := helpers.MatchUserRoleToUid(ctx, foundNote.Created_by); err != nil {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": "Only the author or a manager can change this Note"})
+			return
+		}
+
+		var updateObj primitive.D
+
+		if note.Title != nil {
+			updateObj = append(updateObj, bson.E{"title", *note.Title})
+		}
+		if note.Text != nil {
+			updateObj = append(updateObj, bson.E{"text", *note.Text})
+		}
+
+		updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj = append(updateObj, bson.E{"updated_by", ctx.GetString("user_id")})
+		updateObj = append(updateObj, bson.E{"updated_at", updatedAt})
+
+		result, err := noteCollection.UpdateOne(
+			c,
+			bson.M{"note_id": noteId},
+			bson.D{
+				{"$set", updateObj},
+			},
+		)
+		if err != nil {
+			msg := fmt.Sprintf("Note update failed")
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			return
+		}
+		if result.MatchedCount == 0 {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Note was not found"})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, result)
+	}
+}
+
+// DeleteNote removes a note. Only its author or a manager may remove it.
+func DeleteNote() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		noteId := ctx.Param("note_id")
+
+		var note models.Note
+		if err := noteCollection.FindOne(c, bson.M{"note_id": noteId}).Decode(&note); err != nil {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Note was not found"})
+			return
+		}
+		if err := helpers.MatchUserRoleToUid(ctx, note.Created_by); err != nil {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": "Only the author or a manager can delete this Note"})
+			return
+		}
+
+		result, err := noteCollection.DeleteOne(c, bson.M{"note_id": noteId})
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Note was not deleted"})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, result)
+	}
+}
+
+func newNote(parentType string, parentId string, title string, text string, userId string) models.Note {
+	var note models.Note
+
+	note.Parent_type = &parentType
+	note.Parent_id = &parentId
+	note.Title = title
+	note.Text = text
+	note.Created_by = userId
+	note.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	note.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	note.ID = primitive.NewObjectID()
+	note.Note_id = note.ID.Hex()
+
+	return note
+}
+
+// checkNoteParent checks that the order, order item or table a note is
+// left on exists. Customers are not stored, so their ids are not checked.
+func checkNoteParent(c context.Context, parentType string, parentId string) error {
+	var collection *mongo.Collection
+	var filter bson.M
+	switch parentType {
+	case models.NoteOrder:
+		collection, filter = orderCollection, bson.M{"order_id": parentId}
+	case models.NoteOrderItem:
+		collection, filter = orderItemCollection, bson.M{"order_item_id": parentId}
+	case models.NoteTable:
+		collection, filter = tableCollection, bson.M{"table_id": parentId}
+	default:
+		return nil
+	}
+
+	count, err := collection.CountDocuments(c, filter)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("%s %s does not exist", parentType, parentId)
+	}
+
+	return nil
+}
+
+// orderItemNotes loads the notes of order items by order item id, oldest
+// first, as they are printed on kitchen tickets.
+func orderItemNotes(c context.Context, orderItemIds []string) (map[string][]string, error) {
+	result, err := noteCollection.Find(
+		c,
+		bson.M{"parent_type": models.NoteOrderItem, "parent_id": bson.M{"$in": orderItemIds}},
+		options.Find().SetSort(bson.D{{"created_at", 1}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var notes []models.Note
+	if err = result.All(c, &notes); err != nil {
+		return nil, err
+	}
+
+	byOrderItem := map[string][]string{}
+	for _, note := range notes {
+		byOrderItem[*note.Parent_id] = append(byOrderItem[*note.Parent_id], note.Text)
+	}
+
+	return byOrderItem, nil
+}
@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -9,15 +10,41 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/kwamekyeimonies/restaurant_management_system_backend/database"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/helpers"
 	"github.com/kwamekyeimonies/restaurant_management_system_backend/models"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 var orderCollection *mongo.Collection = database.OpenCollection(database.Client, "order")
 
+// orderTransitions lists the statuses an order may move to from each status.
+// PAID and CANCELLED orders are closed and cannot change any more.
+var orderTransitions = map[string][]string{
+	models.OrderOpen:          {models.OrderSentToKitchen, models.OrderPaid, models.OrderCancelled},
+	models.OrderSentToKitchen: {models.OrderServed, models.OrderPaid, models.OrderCancelled},
+	models.OrderServed:        {models.OrderSentToKitchen, models.OrderPaid},
+	models.OrderPaid:          {},
+	models.OrderCancelled:     {},
+}
+
+// errOrderChanged is returned when an order was moved to another table
+// since it was read.
+var errOrderChanged = errors.New("Order was changed by someone else, please retry")
+
+// transitionRoles limits who may move an order to a status by hand, any
+// staff on the route may ask for the others. PAID cannot be asked for at
+// all, an order is only paid by settling its invoice.
+var transitionRoles = map[string][]string{
+	models.OrderCancelled: {models.RoleManager},
+}
+
+type OrderTransitionRequest struct {
+	Status *string `json:"status" validate:"required,eq=OPEN|eq=SENT_TO_KITCHEN|eq=SERVED|eq=CANCELLED"`
+	Reason string  `json:"reason"`
+}
+
 func GetOrders() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 
@@ -56,6 +83,7 @@ func GetOrder() gin.HandlerFunc {
 func CreateOrder() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		cx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
 		var table models.Table
 		var order models.Order
 		if err := ctx.BindJSON(&order); err != nil {
@@ -67,18 +95,14 @@ func CreateOrder() gin.HandlerFunc {
 
 		if validationErr != nil {
 			ctx.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
-			// return
+			return
 		}
 
-		if order.Table_id != nil {
-
-			err := tableCollection.FindOne(cx, bson.M{"table_id": order.Table_id}).Decode(&table)
-			defer cancel()
-			if err != nil {
-				msg := fmt.Sprintf("Message: Table was not found")
-				ctx.JSON(http.StatusInternalServerError, gin.H{"error": msg})
-				return
-			}
+		err := tableCollection.FindOne(cx, bson.M{"table_id": order.Table_id}).Decode(&table)
+		if err != nil {
+			msg := fmt.Sprintf("Message: Table was not found")
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			return
 		}
 
 		order.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
@@ -86,16 +110,39 @@ func CreateOrder() gin.HandlerFunc {
 
 		order.ID = primitive.NewObjectID()
 		order.Order_id = order.ID.Hex()
+		status := models.OrderOpen
+		order.Status = &status
+		order.Status_history = nil
 
-		result, insertErr := orderCollection.InsertOne(cx, order)
+		session, err := database.Client.StartSession()
+		if err != nil {
+			log.Println(err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Order Item creastion unsuccessfull"})
+			return
+		}
+		defer session.EndSession(cx)
+
+		// The order is only created if its table can be seated with it.
+		result, insertErr := session.WithTransaction(cx, func(sc mongo.SessionContext) (interface{}, error) {
+			result, err := orderCollection.InsertOne(sc, order)
+			if err != nil {
+				return nil, err
+			}
+
+			return result, seatTable(sc, order.Table_id, order.Order_id)
+		})
 
+		if insertErr == errTableOccupied {
+			ctx.JSON(http.StatusConflict, gin.H{"error": insertErr.Error()})
+			return
+		}
 		if insertErr != nil {
+			log.Println(insertErr)
 			msg := fmt.Sprintf("Order Item creastion unsuccessfull")
 			ctx.JSON(http.StatusInternalServerError, gin.H{"error": msg})
 			return
 		}
 
-		defer cancel()
 		ctx.JSON(http.StatusOK, result)
 	}
 }
@@ -103,8 +150,10 @@ func CreateOrder() gin.HandlerFunc {
 func UpdateOrder() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		cx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
 		orderId := ctx.Param("order_id")
 		var order models.Order
+		var foundOrder models.Order
 		var table models.Table
 
 		var updateObj primitive.D
@@ -113,56 +162,223 @@ func UpdateOrder() gin.HandlerFunc {
 			return
 		}
 
-		if order.Table_id != nil {
-			err := orderCollection.FindOne(cx, bson.M{"table_id": order.Table_id}).Decode(&table)
-			defer cancel()
+		if err := orderCollection.FindOne(cx, bson.M{"order_id": orderId}).Decode(&foundOrder); err != nil {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Order was not found"})
+			return
+		}
+
+		if orderIsClosed(foundOrder) {
+			ctx.JSON(http.StatusConflict, gin.H{"error": "Order is closed and can no longer be changed"})
+			return
+		}
 
+		moved := order.Table_id != "" && order.Table_id != foundOrder.Table_id
+		if moved {
+			err := tableCollection.FindOne(cx, bson.M{"table_id": order.Table_id}).Decode(&table)
 			if err != nil {
-				msg := fmt.Sprintf("Message: Menu was not found")
+				msg := fmt.Sprintf("Message: Table was not found")
 				ctx.JSON(http.StatusInternalServerError, gin.H{"error": msg})
 				return
 			}
-			updateObj = append(updateObj, bson.E{"menu", order.Table_id})
+			updateObj = append(updateObj, bson.E{"table_id", order.Table_id})
 		}
 
 		order.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		updateObj = append(updateObj, bson.E{"updated_at", order.Updated_at})
-		upsert := true
 
-		filter := bson.M{"order_id": orderId}
-		opt := options.UpdateOptions{
-			Upsert: &upsert,
+		session, err := database.Client.StartSession()
+		if err != nil {
+			log.Println(err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "order item update failed"})
+			return
 		}
+		defer session.EndSession(cx)
+
+		// Moving an order to another table frees the old table and seats
+		// the new one in the same transaction. The order is only moved from
+		// the table it was read at.
+		result, err := session.WithTransaction(cx, func(sc mongo.SessionContext) (interface{}, error) {
+			result, err := orderCollection.UpdateOne(
+				sc,
+				bson.M{"order_id": orderId, "table_id": foundOrder.Table_id},
+				bson.D{
+					{"$set", updateObj},
+				},
+			)
+			if err != nil {
+				return nil, err
+			}
+			if result.MatchedCount == 0 {
+				return nil, errOrderChanged
+			}
+			if !moved {
+				return result, nil
+			}
 
-		result, err := orderCollection.UpdateOne(
-			ctx,
-			filter,
-			bson.D{
-				{"$st", updateObj},
-			},
-			&opt,
-		)
+			if err := freeTable(sc, foundOrder.Table_id, orderId); err != nil {
+				return nil, err
+			}
+			return result, seatTable(sc, order.Table_id, orderId)
+		})
 
+		if err == errTableOccupied || err == errOrderChanged {
+			ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		if err != nil {
+			log.Println(err)
 			msg := fmt.Sprintf("order item update failed")
 			ctx.JSON(http.StatusInternalServerError, gin.H{"error": msg})
 			return
 		}
 
-		defer cancel()
 		ctx.JSON(http.StatusOK, result)
 	}
 }
 
-func OrderItemOrderCreator(order models.Order) string {
+func TransitionOrder() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		cx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		orderId := ctx.Param("order_id")
+		var request OrderTransitionRequest
+		var order models.Order
+
+		if err := ctx.BindJSON(&request); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validationErr := validate.Struct(request)
+		if validationErr != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		if roles, ok := transitionRoles[*request.Status]; ok {
+			if err := helpers.CheckUserRole(ctx, roles...); err != nil {
+				ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		if err := orderCollection.FindOne(cx, bson.M{"order_id": orderId}).Decode(&order); err != nil {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Order was not found"})
+			return
+		}
+
+		session, err := database.Client.StartSession()
+		if err != nil {
+			log.Println(err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Order status was not changed"})
+			return
+		}
+		defer session.EndSession(cx)
+
+		// A cancelled order gives its stock back and frees its table in the
+		// same transaction as the status change.
+		_, err = session.WithTransaction(cx, func(sc mongo.SessionContext) (interface{}, error) {
+			return nil, transitionOrder(sc, &order, *request.Status, ctx.GetString("user_id"), request.Reason)
+		})
+		if err != nil {
+			ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, order)
+	}
+}
+
+// orderStatus treats orders created before statuses existed as open.
+func orderStatus(order models.Order) string {
+	if order.Status == nil {
+		return models.OrderOpen
+	}
+
+	return *order.Status
+}
+
+func orderIsClosed(order models.Order) bool {
+	status := orderStatus(order)
+	return status == models.OrderPaid || status == models.OrderCancelled
+}
+
+// transitionOrder moves an order to a new status and records who did it in
+// the status history. The update only applies if nobody changed the status
+// since the order was read. Cancelling an order takes its items off the
+// kitchen queue, puts back the stock and portions of every item not already
+// voided and frees its table.
+func transitionOrder(c context.Context, order *models.Order, status string, userId string, reason string) error {
+	from := orderStatus(*order)
+
+	allowed := false
+	for _, next := range orderTransitions[from] {
+		if next == status {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return fmt.Errorf("Order cannot move from %s to %s", from, status)
+	}
+
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	change := models.OrderStatusChange{
+		From:       from,
+		To:         status,
+		Reason:     reason,
+		Changed_by: userId,
+		Changed_at: now,
+	}
+
+	result, err := orderCollection.UpdateOne(
+		c,
+		bson.M{"order_id": order.Order_id, "status": order.Status},
+		bson.D{
+			{"$set", bson.D{{"status", status}, {"updated_at", now}}},
+			{"$push", bson.D{{"status_history", change}}},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("Order status was changed by someone else, please retry")
+	}
+
+	order.Status = &status
+	order.Updated_at = now
+	order.Status_history = append(order.Status_history, change)
+
+	if status == models.OrderCancelled {
+		if err := cancelPrep(c, order.Order_id); err != nil {
+			return err
+		}
+		if err := restoreOrderStock(c, order.Order_id); err != nil {
+			return err
+		}
+		return freeTable(c, order.Table_id, order.Order_id)
+	}
+
+	return nil
+}
+
+// OrderItemOrderCreator inserts the order behind a pack of order items and
+// seats its table. Pass a session context to run it inside a transaction.
+func OrderItemOrderCreator(c context.Context, order *models.Order) error {
 	order.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 	order.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-	cx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
-	order.ID = primitive.NewObjectID()
-	order.Order_id = order.ID.Hex()
-	orderCollection.InsertOne(cx, order)
-	defer cancel()
+	if order.ID.IsZero() {
+		order.ID = primitive.NewObjectID()
+		order.Order_id = order.ID.Hex()
+	}
+	status := models.OrderOpen
+	order.Status = &status
 
-	return order.Order_id
+	if _, err := orderCollection.InsertOne(c, order); err != nil {
+		return err
+	}
 
+	return seatTable(c, order.Table_id, order.Order_id)
 }
@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/helpers"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/models"
+)
+
+func TestOrderTransitions(t *testing.T) {
+	tests := []struct {
+		from    string
+		to      string
+		allowed bool
+	}{
+		{models.OrderOpen, models.OrderSentToKitchen, true},
+		{models.OrderOpen, models.OrderPaid, true},
+		{models.OrderOpen, models.OrderCancelled, true},
+		{models.OrderOpen, models.OrderServed, false},
+		{models.OrderSentToKitchen, models.OrderServed, true},
+		{models.OrderSentToKitchen, models.OrderPaid, true},
+		{models.OrderSentToKitchen, models.OrderCancelled, true},
+		{models.OrderSentToKitchen, models.OrderOpen, false},
+		{models.OrderServed, models.OrderSentToKitchen, true},
+		{models.OrderServed, models.OrderPaid, true},
+		{models.OrderServed, models.OrderCancelled, false},
+		{models.OrderPaid, models.OrderOpen, false},
+		{models.OrderPaid, models.OrderCancelled, false},
+		{models.OrderCancelled, models.OrderOpen, false},
+		{models.OrderCancelled, models.OrderPaid, false},
+	}
+
+	for _, tt := range tests {
+		allowed := false
+		for _, next := range orderTransitions[tt.from] {
+			allowed = allowed || next == tt.to
+		}
+		if allowed != tt.allowed {
+			t.Errorf("%s -> %s allowed = %v, want %v", tt.from, tt.to, allowed, tt.allowed)
+		}
+	}
+}
+
+func TestOrderTransitionRequest(t *testing.T) {
+	tests := []struct {
+		status  string
+		wantErr bool
+	}{
+		{models.OrderOpen, false},
+		{models.OrderSentToKitchen, false},
+		{models.OrderServed, false},
+		{models.OrderCancelled, false},
+		{models.OrderPaid, true},
+		{"CLOSED", true},
+	}
+
+	for _, tt := range tests {
+		status := tt.status
+		err := validate.Struct(OrderTransitionRequest{Status: &status})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("transition request to %s error = %v, wantErr %v", tt.status, err, tt.wantErr)
+		}
+	}
+}
+
+func TestTransitionRoles(t *testing.T) {
+	tests := []struct {
+		status  string
+		role    string
+		allowed bool
+	}{
+		{models.OrderSentToKitchen, models.RoleKitchen, true},
+		{models.OrderServed, models.RoleWaiter, true},
+		{models.OrderCancelled, models.RoleAdmin, true},
+		{models.OrderCancelled, models.RoleManager, true},
+		{models.OrderCancelled, models.RoleWaiter, false},
+		{models.OrderCancelled, models.RoleCashier, false},
+		{models.OrderCancelled, models.RoleKitchen, false},
+	}
+
+	for _, tt := range tests {
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		ctx.Set("role", tt.role)
+
+		allowed := true
+		if roles, ok := transitionRoles[tt.status]; ok {
+			allowed = helpers.CheckUserRole(ctx, roles...) == nil
+		}
+		if allowed != tt.allowed {
+			t.Errorf("%s moving an order to %s allowed = %v, want %v", tt.role, tt.status, allowed, tt.allowed)
+		}
+	}
+}
+
+func TestOrderIsClosed(t *testing.T) {
+	tests := []struct {
+		status *string
+		closed bool
+	}{
+		{nil, false},
+		{stringPtr(models.OrderOpen), false},
+		{stringPtr(models.OrderSentToKitchen), false},
+		{stringPtr(models.OrderServed), false},
+		{stringPtr(models.OrderPaid), true},
+		{stringPtr(models.OrderCancelled), true},
+	}
+
+	for _, tt := range tests {
+		if got := orderIsClosed(models.Order{Status: tt.status}); got != tt.closed {
+			t.Errorf("orderIsClosed(%v) = %v, want %v", orderStatus(models.Order{Status: tt.status}), got, tt.closed)
+		}
+	}
+}
+
+func stringPtr(value string) *string {
+	return &value
+}
@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -13,13 +14,17 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 var orderItemCollection *mongo.Collection = database.OpenCollection(database.Client, "orderItem")
 
+var errOrderItemChanged = errors.New("order item was changed by another request")
+
+// OrderItemPack opens a new order on Table_id, or adds items to the existing
+// order when Order_id is set.
 type OrderItemPack struct {
 	Table_id    *string
+	Order_id    *string
 	Order_items []models.OrderItem
 }
 
@@ -60,28 +65,188 @@ func GetOrderItem() gin.HandlerFunc {
 	}
 }
 
+// ItemsByOrder joins the items of an order with their food, order and table
+// records. It returns a single document holding the table_number, the
+// payment_due and the itemised order_items of the order.
 func ItemsByOrder(id string) (OrderItemPack []primitive.M, err error) {
+	var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	matchStage := bson.D{{"$match", bson.D{{"order_id", id}}}}
+
+	lookupFoodStage := bson.D{{"$lookup", bson.D{{"from", "food"}, {"localField", "food_id"}, {"foreignField", "food_id"}, {"as", "food"}}}}
+	unwindFoodStage := bson.D{{"$unwind", bson.D{{"path", "$food"}, {"preserveNullAndEmptyArrays", true}}}}
+
+	lookupBundleStage := bson.D{{"$lookup", bson.D{{"from", "bundle"}, {"localField", "bundle_id"}, {"foreignField", "bundle_id"}, {"as", "bundle"}}}}
+	unwindBundleStage := bson.D{{"$unwind", bson.D{{"path", "$bundle"}, {"preserveNullAndEmptyArrays", true}}}}
+
+	lookupOrderStage := bson.D{{"$lookup", bson.D{{"from", "order"}, {"localField", "order_id"}, {"foreignField", "order_id"}, {"as", "order"}}}}
+	unwindOrderStage := bson.D{{"$unwind", bson.D{{"path", "$order"}, {"preserveNullAndEmptyArrays", true}}}}
+
+	lookupTableStage := bson.D{{"$lookup", bson.D{{"from", "table"}, {"localField", "order.table_id"}, {"foreignField", "table_id"}, {"as", "table"}}}}
+	unwindTableStage := bson.D{{"$unwind", bson.D{{"path", "$table"}, {"preserveNullAndEmptyArrays", true}}}}
+
+	lookupNotesStage := bson.D{{"$lookup", bson.D{
+		{"from", "note"},
+		{"let", bson.D{{"order_item_id", "$order_item_id"}}},
+		{"pipeline", mongo.Pipeline{
+			bson.D{{"$match", bson.D{{"$expr", bson.D{{"$and", bson.A{
+				bson.D{{"$eq", bson.A{"$parent_type", models.NoteOrderItem}}},
+				bson.D{{"$eq", bson.A{"$parent_id", "$$order_item_id"}}},
+			}}}}}}},
+			bson.D{{"$sort", bson.D{{"created_at", 1}}}},
+			bson.D{{"$project", bson.D{{"_id", 0}, {"note_id", 1}, {"title", 1}, {"text", 1}}}},
+		}},
+		{"as", "notes"},
+	}}}
+
+	// Prices are Money documents, so line totals multiply the minor unit amount.
+	priceStage := bson.D{
+		{
+			"$addFields", bson.D{
+				{"unit_price", bson.D{{"$ifNull", bson.A{"$unit_price", "$food.price"}}}},
+				{"quantity", bson.D{{"$ifNull", bson.A{"$quantity", 1}}}},
+			},
+		},
+	}
+
+	projectStage := bson.D{
+		{
+			"$project", bson.D{
+				{"_id", 0},
+				{"order_item_id", 1},
+				{"order_id", 1},
+				{"food_id", 1},
+				{"bundle_id", 1},
+				{"food_name", bson.D{{"$ifNull", bson.A{"$food.name", "$bundle.name"}}}},
+				{"food_image", "$food.food_image"},
+				{"quantity", 1},
+				{"portion", 1},
+				{"prep_status", 1},
+				{"unit_price", 1},
+				{"line_total", bson.D{
+					{"amount", bson.D{{"$multiply", bson.A{"$unit_price.amount", "$quantity"}}}},
+					{"currency", "$unit_price.currency"},
+				}},
+				{"table_id", "$table.table_id"},
+				{"table_number", "$table.table_number"},
+				{"modifiers", 1},
+				{"components", 1},
+				{"notes", 1},
+			},
+		},
+	}
+
+	groupStage := bson.D{
+		{
+			"$group", bson.D{
+				{"_id", bson.D{{"order_id", "$order_id"}, {"table_id", "$table_id"}, {"table_number", "$table_number"}}},
+				{"payment_due", bson.D{{"$sum", "$line_total.amount"}}},
+				{"currency", bson.D{{"$first", "$line_total.currency"}}},
+				{"total_count", bson.D{{"$sum", 1}}},
+				{"order_items", bson.D{{"$push", "$$ROOT"}}},
+			},
+		},
+	}
 
+	projectGroupStage := bson.D{
+		{
+			"$project", bson.D{
+				{"_id", 0},
+				{"order_id", "$_id.order_id"},
+				{"table_id", "$_id.table_id"},
+				{"table_number", "$_id.table_number"},
+				{"payment_due", bson.D{{"amount", "$payment_due"}, {"currency", "$currency"}}},
+				{"total_count", 1},
+				{"order_items", 1},
+			},
+		},
+	}
+
+	result, err := orderItemCollection.Aggregate(c, mongo.Pipeline{
+		matchStage,
+		lookupFoodStage,
+		unwindFoodStage,
+		lookupBundleStage,
+		unwindBundleStage,
+		lookupOrderStage,
+		unwindOrderStage,
+		lookupTableStage,
+		unwindTableStage,
+		lookupNotesStage,
+		priceStage,
+		projectStage,
+		groupStage,
+		projectGroupStage,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err = result.All(c, &OrderItemPack); err != nil {
+		return nil, err
+	}
+
+	return OrderItemPack, nil
 }
 
 func CreateOrderItem() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
 
-		//var orderItem models.OrderItem
 		var orderItemPack OrderItemPack
 		var order models.Order
+		var table models.Table
 		if err := ctx.BindJSON(&orderItemPack); err != nil {
 			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		order.Order_Date, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-		orderItemsToBeinserted := []interface{}{}
-		order.Table_id = *orderItemPack.Table_id
-		order_id := OrderItemOrderCreator(order)
 
+		if len(orderItemPack.Order_items) == 0 {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "At least one Order item is required"})
+			return
+		}
+
+		newOrder := orderItemPack.Order_id == nil
+		if newOrder {
+			if orderItemPack.Table_id == nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": "Table_id is required to open an Order"})
+				return
+			}
+
+			if err := tableCollection.FindOne(c, bson.M{"table_id": orderItemPack.Table_id}).Decode(&table); err != nil {
+				ctx.JSON(http.StatusNotFound, gin.H{"error": "Table was not found"})
+				return
+			}
+
+			order.Order_Date, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+			order.Table_id = *orderItemPack.Table_id
+			order.ID = primitive.NewObjectID()
+			order.Order_id = order.ID.Hex()
+		} else {
+			if err := orderCollection.FindOne(c, bson.M{"order_id": orderItemPack.Order_id}).Decode(&order); err != nil {
+				ctx.JSON(http.StatusNotFound, gin.H{"error": "Order was not found"})
+				return
+			}
+
+			if orderIsClosed(order) {
+				ctx.JSON(http.StatusConflict, gin.H{"error": "Order is closed, items can no longer be added"})
+				return
+			}
+		}
+
+		// Every item is validated before anything is written, the order, its
+		// items, their kitchen tickets and the stock they use are then written
+		// in a single transaction.
+		orderItems := make([]models.OrderItem, 0, len(orderItemPack.Order_items))
+		orderItemsToBeinserted := []interface{}{}
+		foods := map[string]models.Food{}
+		portions := map[string]int{}
 		for _, orderItem := range orderItemPack.Order_items {
-			orderItem.Order_id = order_id
+			orderItem.Order_id = order.Order_id
+			portion := orderItemPortion(orderItem)
+			orderItem.Portion = &portion
 			validationErr := validate.Struct(orderItem)
 
 			if validationErr != nil {
@@ -89,68 +254,364 @@ func CreateOrderItem() gin.HandlerFunc {
 				return
 			}
 
+			// The price always comes from the food or bundle record. A client
+			// that sends a different price is working from a stale or tampered
+			// menu.
+			var price models.Money
+			var name string
+			if orderItem.Bundle_id != nil {
+				if len(orderItem.Modifiers) > 0 {
+					ctx.JSON(http.StatusBadRequest, gin.H{"error": "Modifiers of a bundle are picked for its components"})
+					return
+				}
+
+				priced, err := orderableBundle(c, *orderItem.Bundle_id, orderItem.Components)
+				if err != nil {
+					ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+					return
+				}
+				for foodId, food := range priced.foods {
+					foods[foodId] = food
+				}
+				for _, component := range priced.components {
+					portions[component.Food_id] += *orderItem.Quantity
+				}
+
+				price, name = priced.price, *priced.bundle.Name
+				portion = models.DefaultPortion
+				orderItem.Components = priced.components
+				orderItem.Stock_used = priced.stockUsage(*orderItem.Quantity)
+			} else {
+				food, err := orderableFood(c, *orderItem.Food_id)
+				if err != nil {
+					ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+					return
+				}
+				foods[food.Food_id] = food
+				portions[food.Food_id] += *orderItem.Quantity
+
+				var modifiers []models.SelectedModifier
+				price, modifiers, err = modifiedUnitPrice(food, portion, orderItem.Modifiers)
+				if err != nil {
+					ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+					return
+				}
+
+				name = foodName(food)
+				orderItem.Modifiers = modifiers
+				orderItem.Components = nil
+				orderItem.Stock_used = stockUsage(food, portion, *orderItem.Quantity)
+			}
+			if orderItem.Unit_price != nil && !orderItem.Unit_price.Equal(price) {
+				msg := fmt.Sprintf("Unit price %s for %s does not match the menu price %s", orderItem.Unit_price, name, price)
+				ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": msg})
+				return
+			}
+
 			orderItem.ID = primitive.NewObjectID()
 			orderItem.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 			orderItem.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 			orderItem.Order_item_id = orderItem.ID.Hex()
-			var num = ToFixed(*orderItem.Unit_price, 2)
-			orderItem.Unit_price = &num
+			orderItem.Version = 0
+			orderItem.Restocked = false
+			prepStatus := models.PrepQueued
+			orderItem.Prep_status = &prepStatus
+			orderItem.Unit_price = &price
+			orderItem.Portion = &portion
+			orderItem.Bill_id = nil
+			orderItems = append(orderItems, orderItem)
 			orderItemsToBeinserted = append(orderItemsToBeinserted, orderItem)
 		}
-		insertedOrderItems, err := orderItemCollection.InsertMany(c, orderItemsToBeinserted)
 
+		session, err := database.Client.StartSession()
 		if err != nil {
-			log.Fatal(err)
+			log.Println(err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Order creation failed"})
+			return
 		}
-		defer cancel()
-		ctx.JSON(http.StatusOK, insertedOrderItems)
+		defer session.EndSession(c)
+
+		var queued models.Order
+		_, err = session.WithTransaction(c, func(sc mongo.SessionContext) (interface{}, error) {
+			if newOrder {
+				if err := OrderItemOrderCreator(sc, &order); err != nil {
+					return nil, err
+				}
+			}
+
+			if err := takePortions(sc, foods, portions); err != nil {
+				return nil, err
+			}
+			if _, err := orderItemCollection.InsertMany(sc, orderItemsToBeinserted); err != nil {
+				return nil, err
+			}
+			var notes []interface{}
+			for _, orderItem := range orderItems {
+				for _, text := range orderItem.Notes {
+					notes = append(notes, newNote(models.NoteOrderItem, orderItem.Order_item_id, "", text, ctx.GetString("user_id")))
+				}
+			}
+			if len(notes) > 0 {
+				if _, err := noteCollection.InsertMany(sc, notes); err != nil {
+					return nil, err
+				}
+			}
+			for _, orderItem := range orderItems {
+				if err := adjustStock(sc, orderItem.Stock_used, false); err != nil {
+					return nil, err
+				}
+			}
+
+			if err := queueKitchenTickets(sc, order, orderItems, foods); err != nil {
+				return nil, err
+			}
+
+			// Queuing tickets sends the order to the kitchen, a served order
+			// goes back there when more is ordered.
+			queued = order
+			if status := orderStatus(order); status == models.OrderOpen || status == models.OrderServed {
+				return nil, transitionOrder(sc, &queued, models.OrderSentToKitchen, ctx.GetString("user_id"), "items sent to the kitchen")
+			}
+			return nil, nil
+		})
+		var soldOut soldOutError
+		if errors.As(err, &soldOut) {
+			ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": soldOut.Error()})
+			return
+		}
+		if err == errTableOccupied {
+			ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			log.Println(err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Order creation failed"})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"order": queued, "order_items": orderItems})
 	}
 }
 
 func UpdateOrderItem() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
 		var orderItem models.OrderItem
-		orderItemId := ctx.Param("order_item_id")
+		var foundOrderItem models.OrderItem
+		var order models.Order
+		orderItemId := ctx.Param("orderitem_id")
 		filter := bson.M{"order_item_id": orderItemId}
 		var updateObj primitive.D
 
-		if orderItem.Unit_price != nil {
-			updateObj = append(updateObj, bson.E{"unit_price": *&orderItem.Unit_price})
+		if err := ctx.BindJSON(&orderItem); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := orderItemCollection.FindOne(c, filter).Decode(&foundOrderItem); err != nil {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Order Item was not found"})
+			return
+		}
+
+		if err := orderCollection.FindOne(c, bson.M{"order_id": foundOrderItem.Order_id}).Decode(&order); err == nil && orderIsClosed(order) {
+			ctx.JSON(http.StatusConflict, gin.H{"error": "Order is closed, its items can no longer be changed"})
+			return
+		}
+
+		// A billed item is frozen, the invoice holds a snapshot of it.
+		if foundOrderItem.Bill_id != nil && (orderItem.Quantity != nil || orderItem.Portion != nil || orderItem.Food_id != nil || orderItem.Modifiers != nil) {
+			ctx.JSON(http.StatusConflict, gin.H{"error": "Order Item has already been billed"})
+			return
+		}
+
+		// A bundle keeps the foods it was ordered with.
+		if foundOrderItem.Bundle_id != nil && (orderItem.Portion != nil || orderItem.Food_id != nil || orderItem.Modifiers != nil || orderItem.Components != nil) {
+			ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Only the quantity and seat of a bundle can be changed"})
+			return
 		}
 
 		if orderItem.Quantity != nil {
-			updateObj = append(updateObj, bson.E{"quantity": *orderItem.Quantity})
+			if validationErr := validate.StructPartial(orderItem, "Quantity"); validationErr != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+				return
+			}
+			updateObj = append(updateObj, bson.E{"quantity", *orderItem.Quantity})
+		}
+
+		if orderItem.Portion != nil {
+			if validationErr := validate.StructPartial(orderItem, "Portion"); validationErr != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+				return
+			}
+			updateObj = append(updateObj, bson.E{"portion", *orderItem.Portion})
+		}
+
+		if orderItem.Seat != nil {
+			if validationErr := validate.StructPartial(orderItem, "Seat"); validationErr != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+				return
+			}
+			updateObj = append(updateObj, bson.E{"seat", *orderItem.Seat})
 		}
 
 		if orderItem.Food_id != nil {
-			updateObj = append(updateObj, bson.E{"food_id": *orderItem.Food_id})
+			updateObj = append(updateObj, bson.E{"food_id", *orderItem.Food_id})
+		}
+
+		// Changing the food, the portion or the modifiers reprices the item from
+		// the food record. Modifiers are kept when the food stays the same.
+		if orderItem.Food_id != nil || orderItem.Portion != nil || orderItem.Modifiers != nil {
+			foodId, portion := foundOrderItem.Food_id, orderItemPortion(foundOrderItem)
+			modifiers := orderItem.Modifiers
+			if orderItem.Food_id != nil {
+				foodId = orderItem.Food_id
+			}
+			if orderItem.Portion != nil {
+				portion = *orderItem.Portion
+			}
+			if modifiers == nil && *foodId == *foundOrderItem.Food_id {
+				modifiers = foundOrderItem.Modifiers
+			}
+
+			food, err := orderableFood(c, *foodId)
+			if err != nil {
+				ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+				return
+			}
+
+			price, modifiers, err := modifiedUnitPrice(food, portion, modifiers)
+			if err != nil {
+				ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+				return
+			}
+			updateObj = append(updateObj, bson.E{"modifiers", modifiers})
+			if orderItem.Unit_price != nil && !orderItem.Unit_price.Equal(price) {
+				msg := fmt.Sprintf("Unit price %s for %s does not match the menu price %s", orderItem.Unit_price, foodName(food), price)
+				ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": msg})
+				return
+			}
+			updateObj = append(updateObj, bson.E{"unit_price", price})
+		}
+
+		// Changing what was ordered moves stock, what the item used is put
+		// back and its new usage is taken. Counted portions are moved the
+		// same way.
+		var stockUsed []models.RecipeItem
+		foods := map[string]models.Food{}
+		oldPortions, newPortions := map[string]int{}, map[string]int{}
+		restock := orderItem.Quantity != nil || orderItem.Food_id != nil || orderItem.Portion != nil
+		if restock {
+			oldQuantity := 1
+			if foundOrderItem.Quantity != nil {
+				oldQuantity = *foundOrderItem.Quantity
+			}
+			quantity := oldQuantity
+			if orderItem.Quantity != nil {
+				quantity = *orderItem.Quantity
+			}
+
+			// The foods of a bundle are its components, each in a regular
+			// portion.
+			type usedFood struct {
+				oldId   string
+				newId   string
+				portion string
+			}
+			var used []usedFood
+			if foundOrderItem.Bundle_id != nil {
+				for _, component := range foundOrderItem.Components {
+					used = append(used, usedFood{component.Food_id, component.Food_id, models.DefaultPortion})
+				}
+			} else {
+				foodId, portion := *foundOrderItem.Food_id, orderItemPortion(foundOrderItem)
+				if orderItem.Food_id != nil {
+					foodId = *orderItem.Food_id
+				}
+				if orderItem.Portion != nil {
+					portion = *orderItem.Portion
+				}
+				used = append(used, usedFood{*foundOrderItem.Food_id, foodId, portion})
+			}
+
+			for _, item := range used {
+				var food models.Food
+				if err := foodCollection.FindOne(c, bson.M{"food_id": item.newId}).Decode(&food); err != nil {
+					ctx.JSON(http.StatusNotFound, gin.H{"error": "Food Item was not found"})
+					return
+				}
+				if quantity > oldQuantity && foodIsSoldOut(food) {
+					ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": soldOutError{name: foodName(food)}.Error()})
+					return
+				}
+
+				foods[food.Food_id] = food
+				oldPortions[item.oldId] += oldQuantity
+				newPortions[item.newId] += quantity
+				stockUsed = append(stockUsed, stockUsage(food, item.portion, quantity)...)
+			}
+			updateObj = append(updateObj, bson.E{"stock_used", stockUsed})
 		}
 
 		orderItem.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-		updateObj = append(updateObj, bson.E{"updated_at": orderItem.Updated_at})
+		updateObj = append(updateObj, bson.E{"updated_at", orderItem.Updated_at})
 
-		upsert := true
-		opt := options.UpdateOptions{
-			Upsert: &upsert,
+		session, err := database.Client.StartSession()
+		if err != nil {
+			log.Println(err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Order Item update failed"})
+			return
 		}
+		defer session.EndSession(c)
+
+		result, updateErr := session.WithTransaction(c, func(sc mongo.SessionContext) (interface{}, error) {
+			result, err := orderItemCollection.UpdateOne(
+				sc,
+				bson.M{"order_item_id": orderItemId, "version": versionFilter(foundOrderItem.Version)},
+				bson.D{
+					{"$set", updateObj},
+					{"$inc", bson.D{{"version", 1}}},
+				},
+			)
+			if err != nil {
+				return nil, err
+			}
+			if result.MatchedCount == 0 {
+				return nil, errOrderItemChanged
+			}
+			if !restock {
+				return result, nil
+			}
 
-		result, updateErr := orderItemCollection.UpdateOne(
-			c,
-			filter,
-			bson.D{
-				{"$st", updateObj},
-			},
-			&opt,
-		)
+			for foodId, count := range oldPortions {
+				if err := returnPortions(sc, foodId, count); err != nil {
+					return nil, err
+				}
+			}
+			if err := takePortions(sc, foods, newPortions); err != nil {
+				return nil, err
+			}
+			if err := adjustStock(sc, foundOrderItem.Stock_used, true); err != nil {
+				return nil, err
+			}
+			return result, adjustStock(sc, stockUsed, false)
+		})
 
+		var soldOut soldOutError
+		if errors.As(updateErr, &soldOut) {
+			ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": soldOut.Error()})
+			return
+		}
+		if updateErr == errOrderItemChanged {
+			ctx.JSON(http.StatusConflict, gin.H{"error": "Order Item was changed by someone else, please retry"})
+			return
+		}
 		if updateErr != nil {
 			msg := fmt.Sprintf("Order Item update failed")
 			ctx.JSON(http.StatusInternalServerError, gin.H{"error": msg})
 			return
 		}
 
-		defer cancel()
 		ctx.JSON(http.StatusOK, result)
 	}
 }
@@ -158,15 +619,26 @@ func UpdateOrderItem() gin.HandlerFunc {
 func GetOrderItemsByOrder() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 
-		var orderItemId = ctx.Param("order_item_id")
+		var orderId = ctx.Param("order_id")
 
-		allOrderedItems, err := ItemsByOrder(orderItemId)
+		allOrderedItems, err := ItemsByOrder(orderId)
 
 		if err != nil {
 			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error Occured while Listing Order Items by Order"})
+			return
 		}
 
 		ctx.JSON(http.StatusOK, allOrderedItems)
 
 	}
 }
+
+// orderItemPortion is the portion size of an item, items ordered without one
+// are regular portions.
+func orderItemPortion(orderItem models.OrderItem) string {
+	if orderItem.Portion == nil || *orderItem.Portion == "" {
+		return models.DefaultPortion
+	}
+
+	return *orderItem.Portion
+}
@@ -0,0 +1,357 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/database"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/models"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/payments"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var paymentCollection *mongo.Collection = database.OpenCollection(database.Client, "payment")
+
+var errInvoiceChanged = errors.New("invoice was changed by another payment")
+
+func GetPayments() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		result, err := paymentCollection.Find(c, bson.M{"invoice_id": ctx.Param("invoice_id")})
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while listing Payments"})
+			return
+		}
+
+		var allPayments []bson.M
+		if err = result.All(c, &allPayments); err != nil {
+			log.Println(err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while listing Payments"})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, allPayments)
+	}
+}
+
+// CreatePayment records one tender against an invoice. Card payments are
+// run through the payment provider and can not go over the balance due,
+// cash can and the difference is change owed. The invoice is PAID once its
+// balance reaches zero.
+func CreatePayment() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var payment models.Payment
+		var invoice models.Invoice
+		invoiceId := ctx.Param("invoice_id")
+
+		if err := ctx.BindJSON(&payment); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if validationErr := validate.Struct(payment); validationErr != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		if *payment.Method == models.TenderCard && payment.Card_token == "" {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Card payments need a card_token"})
+			return
+		}
+
+		if err := invoiceCollection.FindOne(c, bson.M{"invoice_id": invoiceId}).Decode(&invoice); err != nil {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Invoice was not found"})
+			return
+		}
+
+		if invoice.Payment_status != nil && *invoice.Payment_status != models.PaymentPending && *invoice.Payment_status != models.PaymentPartiallyPaid {
+			ctx.JSON(http.StatusConflict, gin.H{"error": "Invoice has already been paid"})
+			return
+		}
+
+		balance := invoiceBalance(invoice)
+		if !payment.Amount.IsPositive() || payment.Amount.Currency != balance.Currency {
+			msg := fmt.Sprintf("Payment must be a positive amount in %s", balance.Currency)
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+		if !balance.IsPositive() {
+			ctx.JSON(http.StatusConflict, gin.H{"error": "Invoice has nothing left to pay"})
+			return
+		}
+
+		payment.Applied = payment.Amount.Min(balance)
+		payment.Change = payment.Amount.Sub(payment.Applied)
+		if *payment.Method != models.TenderCash && payment.Change.IsPositive() {
+			msg := fmt.Sprintf("Card payments can not exceed the balance due of %s", balance)
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+
+		payment.ID = primitive.NewObjectID()
+		payment.Payment_id = payment.ID.Hex()
+		payment.Invoice_id = invoice.Invoice_id
+		payment.Status = models.TenderSettled
+		payment.Created_by = ctx.GetString("user_id")
+		payment.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		var provider payments.Provider
+		if *payment.Method == models.TenderCard {
+			var err error
+			if provider, err = payments.Default(); err != nil {
+				log.Println(err)
+				ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Card payments are not available"})
+				return
+			}
+
+			status, msg := chargeCard(c, provider, &payment)
+			if status != http.StatusOK {
+				ctx.JSON(status, gin.H{"error": msg})
+				return
+			}
+		}
+
+		// A pending card payment is kept but only counts once the provider's
+		// webhook settles it.
+		if payment.Status == models.TenderPending {
+			if _, err := paymentCollection.InsertOne(c, payment); err != nil {
+				log.Println(err)
+				ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Payment was not recorded"})
+				return
+			}
+
+			ctx.JSON(http.StatusAccepted, gin.H{"payment": payment, "balance_due": balance})
+			return
+		}
+
+		settled, err := applyPayment(c, invoice, payment, ctx.GetString("user_id"), func(sc mongo.SessionContext) error {
+			_, err := paymentCollection.InsertOne(sc, payment)
+			return err
+		})
+		if err != nil {
+			if provider != nil {
+				if _, refundErr := provider.Refund(c, payment.Transaction_id, payment.Applied); refundErr != nil {
+					log.Println(refundErr)
+				}
+			}
+			if err == errInvoiceChanged {
+				ctx.JSON(http.StatusConflict, gin.H{"error": "Invoice was paid into by someone else, please retry"})
+				return
+			}
+			log.Println(err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Payment was not recorded"})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{
+			"payment":        payment,
+			"amount_paid":    settled.Amount_paid,
+			"balance_due":    settled.Balance_due,
+			"change":         payment.Change,
+			"payment_status": settled.Payment_status,
+		})
+	}
+}
+
+// PaymentWebhook takes settlement notifications for pending card payments
+// from a payment provider. Notifications for payments that are no longer
+// pending are acknowledged and ignored, providers send them more than once.
+func PaymentWebhook() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		provider, ok := payments.Lookup(ctx.Param("provider"))
+		if !ok {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Payment provider was not found"})
+			return
+		}
+
+		event, err := provider.ParseWebhook(ctx.Request)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var payment models.Payment
+		filter := bson.M{"provider": provider.Name(), "transaction_id": event.Transaction_id}
+		if err := paymentCollection.FindOne(c, filter).Decode(&payment); err != nil {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Payment was not found"})
+			return
+		}
+		if payment.Status != models.TenderPending {
+			ctx.JSON(http.StatusOK, gin.H{"payment_id": payment.Payment_id, "status": payment.Status})
+			return
+		}
+
+		pendingFilter := bson.M{"payment_id": payment.Payment_id, "status": models.TenderPending}
+
+		if event.Status == payments.StatusDeclined {
+			_, err := paymentCollection.UpdateOne(c, pendingFilter, bson.D{{"$set", bson.D{{"status", models.TenderDeclined}}}})
+			if err != nil {
+				log.Println(err)
+				ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Payment was not updated"})
+				return
+			}
+
+			ctx.JSON(http.StatusOK, gin.H{"payment_id": payment.Payment_id, "status": models.TenderDeclined})
+			return
+		}
+
+		// Only a settled event moves money, the payment stays pending on
+		// any other status until the provider settles or declines it.
+		if event.Status != payments.StatusSettled {
+			log.Printf("payment %s: ignoring %s webhook status %q", payment.Payment_id, provider.Name(), event.Status)
+			ctx.JSON(http.StatusOK, gin.H{"payment_id": payment.Payment_id, "status": payment.Status})
+			return
+		}
+
+		var invoice models.Invoice
+		if err := invoiceCollection.FindOne(c, bson.M{"invoice_id": payment.Invoice_id}).Decode(&invoice); err != nil {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Invoice was not found"})
+			return
+		}
+
+		// Other tenders may have paid part of the balance while this payment
+		// was pending, whatever it would overpay goes back to the card.
+		authorized := payment.Applied
+		payment.Applied = payment.Applied.Min(invoiceBalance(invoice))
+		if payment.Applied.IsNegative() {
+			payment.Applied = models.NewMoney(0, authorized.Currency)
+		}
+		payment.Status = models.TenderSettled
+
+		_, err = applyPayment(c, invoice, payment, payment.Created_by, func(sc mongo.SessionContext) error {
+			result, err := paymentCollection.UpdateOne(
+				sc,
+				pendingFilter,
+				bson.D{
+					{"$set", bson.D{{"status", payment.Status}, {"applied", payment.Applied}}},
+				},
+			)
+			if err == nil && result.MatchedCount == 0 {
+				return errInvoiceChanged
+			}
+			return err
+		})
+		if err != nil {
+			log.Println(err)
+			ctx.JSON(http.StatusConflict, gin.H{"error": "Payment could not be settled, please retry"})
+			return
+		}
+
+		if overpaid := authorized.Sub(payment.Applied); overpaid.IsPositive() {
+			if _, err := provider.Refund(c, payment.Transaction_id, overpaid); err != nil {
+				log.Println(err)
+			}
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"payment_id": payment.Payment_id, "status": payment.Status})
+	}
+}
+
+// chargeCard authorizes and captures a card payment. It answers with the
+// HTTP status to report, and a message when that is not 200.
+func chargeCard(c context.Context, provider payments.Provider, payment *models.Payment) (int, string) {
+	payment.Provider = provider.Name()
+
+	result, err := provider.Authorize(c, payments.Request{Amount: payment.Applied, Card_token: payment.Card_token, Reference: payment.Invoice_id})
+	switch {
+	case err == payments.ErrDeclined:
+		return http.StatusPaymentRequired, "Card was declined: " + result.Message
+	case err == payments.ErrTimeout:
+		return http.StatusGatewayTimeout, "Card payment timed out, check the provider before retrying"
+	case err != nil:
+		log.Println(err)
+		return http.StatusBadGateway, "Card payment failed"
+	}
+
+	payment.Transaction_id = result.Transaction_id
+	if result.Status == payments.StatusPending {
+		payment.Status = models.TenderPending
+		return http.StatusOK, ""
+	}
+
+	if _, err := provider.Capture(c, result.Transaction_id, payment.Applied); err != nil {
+		log.Println(err)
+		if _, voidErr := provider.Void(c, result.Transaction_id); voidErr != nil {
+			log.Println(voidErr)
+		}
+		return http.StatusBadGateway, "Card payment could not be captured"
+	}
+
+	return http.StatusOK, ""
+}
+
+// applyPayment adds a settled payment to the invoice and writes the payment
+// with writePayment, in one transaction. The invoice is only updated if its
+// amounts have not changed since it was read, and a payment that settles it
+// is rolled back if its order can not be closed. It returns the invoice as
+// updated.
+func applyPayment(c context.Context, invoice models.Invoice, payment models.Payment, userId string, writePayment func(sc mongo.SessionContext) error) (models.Invoice, error) {
+	filter := invoiceMoneyFilter(invoice)
+
+	invoice.Amount_paid = invoice.Amount_paid.Add(payment.Applied)
+	invoice.Balance_due = invoiceBalance(invoice)
+	status := invoicePaymentStatus(invoice)
+
+	session, err := database.Client.StartSession()
+	if err != nil {
+		return invoice, err
+	}
+	defer session.EndSession(c)
+
+	updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	updateObj := bson.D{
+		{"amount_paid", invoice.Amount_paid},
+		{"balance_due", invoice.Balance_due},
+		{"payment_status", status},
+		{"updated_at", updatedAt},
+	}
+	if invoice.Payment_method == nil {
+		updateObj = append(updateObj, bson.E{"payment_method", payment.Method})
+	}
+
+	_, err = session.WithTransaction(c, func(sc mongo.SessionContext) (interface{}, error) {
+		result, err := invoiceCollection.UpdateOne(
+			sc,
+			filter,
+			bson.D{
+				{"$set", updateObj},
+			},
+		)
+		if err != nil {
+			return nil, err
+		}
+		if result.MatchedCount == 0 {
+			return nil, errInvoiceChanged
+		}
+
+		if err := writePayment(sc); err != nil {
+			return nil, err
+		}
+
+		if status == models.PaymentPaid {
+			settled := invoice
+			settled.Payment_status = &status
+			return nil, onInvoicePaid(sc, settled, userId)
+		}
+		return nil, nil
+	})
+
+	invoice.Payment_status = &status
+	invoice.Updated_at = updatedAt
+	return invoice, err
+}
@@ -0,0 +1,346 @@
+package controllers
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/database"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/models"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/receipts"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var printJobCollection *mongo.Collection = database.OpenCollection(database.Client, "printJob")
+
+// printJobMaxAttempts is how many times a ticket is tried before it is left
+// FAILED, and printJobClaimTimeout is how long an agent has to acknowledge
+// a ticket before it is handed out again.
+const (
+	printJobMaxAttempts  = 5
+	printJobClaimTimeout = 2 * time.Minute
+)
+
+// PrintJobView is a claimed print job with its ticket rendered. Content is
+// base64 for escpos, plain text otherwise.
+type PrintJobView struct {
+	Print_job_id string
+	Station      string
+	Order_id     string
+	Attempts     int
+	Reprint_of   string
+	Content_type string
+	Content      string
+}
+
+type PrintJobAck struct {
+	Status string `validate:"required,eq=PRINTED|eq=FAILED"`
+	Error  string
+}
+
+// GetPrintJobs hands the next queued tickets of a station to a print agent.
+// Every job returned is claimed, the agent must acknowledge it or it is
+// handed out again after printJobClaimTimeout.
+func GetPrintJobs() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		format := ctx.DefaultQuery("format", "text")
+		if format != "text" && format != "escpos" {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "format must be text or escpos"})
+			return
+		}
+		limit, err := strconv.Atoi(ctx.DefaultQuery("limit", "10"))
+		if err != nil || limit < 1 {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive number"})
+			return
+		}
+
+		// A claim that timed out is taken again, as long as the job has
+		// attempts left. Jobs out of attempts are failed first.
+		now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		if err := failExpiredPrintJobs(c, now); err != nil {
+			log.Println(err)
+		}
+		filter := bson.M{"$or": bson.A{
+			bson.M{"status": models.PrintQueued},
+			bson.M{
+				"status":     models.PrintPrinting,
+				"updated_at": bson.M{"$lt": now.Add(-printJobClaimTimeout)},
+				"$expr":      bson.M{"$lt": bson.A{"$attempts", "$max_attempts"}},
+			},
+		}}
+		if station := ctx.Query("station"); station != "" {
+			filter["station"] = station
+		}
+
+		jobs := []PrintJobView{}
+		for len(jobs) < limit {
+			var job models.PrintJob
+			err := printJobCollection.FindOneAndUpdate(
+				c,
+				filter,
+				bson.D{
+					{"$set", bson.D{{"status", models.PrintPrinting}, {"updated_at", now}}},
+					{"$inc", bson.D{{"attempts", 1}}},
+				},
+				options.FindOneAndUpdate().SetSort(bson.D{{"created_at", 1}}).SetReturnDocument(options.After),
+			).Decode(&job)
+			if err == mongo.ErrNoDocuments {
+				break
+			}
+			// Jobs claimed so far are handed out even when a later claim
+			// fails, otherwise they would sit in PRINTING until they time out.
+			if err != nil {
+				log.Println(err)
+				if len(jobs) > 0 {
+					break
+				}
+				ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while claiming Print jobs"})
+				return
+			}
+
+			// A ticket that can not be rendered will not render on a retry
+			// either, it is failed rather than left claimed.
+			body, contentType, err := receipts.RenderTicket(job, format)
+			if err != nil {
+				log.Println(err)
+				if err := failPrintJob(c, job, err.Error()); err != nil {
+					log.Println(err)
+				}
+				continue
+			}
+			content := string(body)
+			if format == "escpos" {
+				content = base64.StdEncoding.EncodeToString(body)
+			}
+
+			jobs = append(jobs, PrintJobView{
+				Print_job_id: job.Print_job_id,
+				Station:      job.Station,
+				Order_id:     job.Order_id,
+				Attempts:     job.Attempts,
+				Reprint_of:   job.Reprint_of,
+				Content_type: contentType,
+				Content:      content,
+			})
+		}
+
+		ctx.JSON(http.StatusOK, jobs)
+	}
+}
+
+// AckPrintJob is how a print agent reports a claimed ticket. A failed
+// ticket is queued again until it runs out of attempts.
+func AckPrintJob() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var ack PrintJobAck
+		var job models.PrintJob
+		printJobId := ctx.Param("print_job_id")
+
+		if err := ctx.BindJSON(&ack); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if validationErr := validate.Struct(ack); validationErr != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		if err := printJobCollection.FindOne(c, bson.M{"print_job_id": printJobId}).Decode(&job); err != nil {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Print job was not found"})
+			return
+		}
+
+		status := ack.Status
+		if status == models.PrintFailed && job.Attempts < job.Max_attempts {
+			status = models.PrintQueued
+		}
+
+		updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		result, err := printJobCollection.UpdateOne(
+			c,
+			bson.M{"print_job_id": printJobId, "status": models.PrintPrinting, "attempts": job.Attempts},
+			bson.D{
+				{"$set", bson.D{{"status", status}, {"last_error", ack.Error}, {"updated_at", updatedAt}}},
+			},
+		)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Print job update failed"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			ctx.JSON(http.StatusConflict, gin.H{"error": "Print job is not claimed by this agent any more"})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"print_job_id": printJobId, "status": status})
+	}
+}
+
+// failPrintJob fails a job claimed by GetPrintJobs.
+func failPrintJob(c context.Context, job models.PrintJob, lastError string) error {
+	updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	_, err := printJobCollection.UpdateOne(
+		c,
+		bson.M{"print_job_id": job.Print_job_id, "status": models.PrintPrinting, "attempts": job.Attempts},
+		bson.D{
+			{"$set", bson.D{{"status", models.PrintFailed}, {"last_error", lastError}, {"updated_at", updatedAt}}},
+		},
+	)
+
+	return err
+}
+
+// failExpiredPrintJobs fails jobs whose last attempt was claimed and never
+// acknowledged, they are not handed out again.
+func failExpiredPrintJobs(c context.Context, now time.Time) error {
+	_, err := printJobCollection.UpdateMany(
+		c,
+		bson.M{
+			"status":     models.PrintPrinting,
+			"updated_at": bson.M{"$lt": now.Add(-printJobClaimTimeout)},
+			"$expr":      bson.M{"$gte": bson.A{"$attempts", "$max_attempts"}},
+		},
+		bson.D{
+			{"$set", bson.D{{"status", models.PrintFailed}, {"last_error", "print agent did not acknowledge the ticket"}, {"updated_at", now}}},
+		},
+	)
+
+	return err
+}
+
+// ReprintPrintJob queues a copy of a ticket, marked as a reprint. Item notes
+// are reloaded, so notes left after the ticket first printed are on it.
+func ReprintPrintJob() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var job models.PrintJob
+		if err := printJobCollection.FindOne(c, bson.M{"print_job_id": ctx.Param("print_job_id")}).Decode(&job); err != nil {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Print job was not found"})
+			return
+		}
+
+		if err := addItemNotes(c, job.Items); err != nil {
+			log.Println(err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while loading the item Notes"})
+			return
+		}
+
+		reprint := newPrintJob(job.Station, job.Order_id, job.Table_number, job.Items)
+		reprint.Reprint_of = job.Print_job_id
+
+		if _, err := printJobCollection.InsertOne(c, reprint); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Print job was not created"})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, reprint)
+	}
+}
+
+func newPrintJob(station string, orderId string, tableNumber string, items []models.PrintJobItem) models.PrintJob {
+	var job models.PrintJob
+
+	job.ID = primitive.NewObjectID()
+	job.Print_job_id = job.ID.Hex()
+	job.Station = station
+	job.Order_id = orderId
+	job.Table_number = tableNumber
+	job.Items = items
+	job.Status = models.PrintQueued
+	job.Max_attempts = printJobMaxAttempts
+	job.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	job.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+	return job
+}
+
+// queueKitchenTickets queues one ticket per prep station for new items of
+// an order. foods holds the food of every item by food id.
+func queueKitchenTickets(c context.Context, order models.Order, orderItems []models.OrderItem, foods map[string]models.Food) error {
+	var table models.Table
+	tableNumber := ""
+	if err := tableCollection.FindOne(c, bson.M{"table_id": order.Table_id}).Decode(&table); err == nil && table.Table_number != nil {
+		tableNumber = fmt.Sprint(*table.Table_number)
+	}
+
+	// The components of a bundle go to the stations of their foods, each
+	// as an item of its own.
+	byStation := map[string][]models.PrintJobItem{}
+	addItem := func(orderItem models.OrderItem, food models.Food, portion string, modifiers []models.SelectedModifier) {
+		station := models.StationKitchen
+		if food.Station != nil {
+			station = *food.Station
+		}
+
+		byStation[station] = append(byStation[station], models.PrintJobItem{
+			Order_item_id: orderItem.Order_item_id,
+			Name:          foodName(food),
+			Quantity:      *orderItem.Quantity,
+			Portion:       portion,
+			Seat:          orderItem.Seat,
+			Modifiers:     modifierNames(modifiers),
+		})
+	}
+	for _, orderItem := range orderItems {
+		if orderItem.Bundle_id != nil {
+			for _, component := range orderItem.Components {
+				addItem(orderItem, foods[component.Food_id], models.DefaultPortion, component.Modifiers)
+			}
+			continue
+		}
+		addItem(orderItem, foods[*orderItem.Food_id], orderItemPortion(orderItem), orderItem.Modifiers)
+	}
+
+	stations := make([]string, 0, len(byStation))
+	for station := range byStation {
+		if err := addItemNotes(c, byStation[station]); err != nil {
+			return err
+		}
+		stations = append(stations, station)
+	}
+	sort.Strings(stations)
+
+	jobs := make([]interface{}, 0, len(stations))
+	for _, station := range stations {
+		jobs = append(jobs, newPrintJob(station, order.Order_id, tableNumber, byStation[station]))
+	}
+
+	_, err := printJobCollection.InsertMany(c, jobs)
+	return err
+}
+
+// addItemNotes sets the notes of every item of a ticket.
+func addItemNotes(c context.Context, items []models.PrintJobItem) error {
+	orderItemIds := make([]string, 0, len(items))
+	for _, item := range items {
+		orderItemIds = append(orderItemIds, item.Order_item_id)
+	}
+
+	notes, err := orderItemNotes(c, orderItemIds)
+	if err != nil {
+		return err
+	}
+	for i := range items {
+		items[i].Notes = notes[items[i].Order_item_id]
+	}
+
+	return nil
+}
@@ -0,0 +1,418 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/database"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var purchaseOrderCollection *mongo.Collection = database.OpenCollection(database.Client, "purchaseOrder")
+
+var errPurchaseOrderChanged = errors.New("purchase order was changed by another request")
+
+// versionFilter matches a document still at the version it was read at.
+// Every write $inc's the version, timestamps are only kept to the second
+// and can not tell two writes in the same second apart. Documents written
+// before versions existed have none and are read as version 0.
+func versionFilter(version int64) interface{} {
+	if version == 0 {
+		return bson.M{"$in": bson.A{0, nil}}
+	}
+
+	return version
+}
+
+// PurchaseOrderUpdate places or cancels a purchase order, or replaces the
+// lines of a draft. Cancelling an order that was partly received closes it
+// without waiting for the rest.
+type PurchaseOrderUpdate struct {
+	Status *string                    `json:"status" validate:"omitempty,eq=ORDERED|eq=CANCELLED"`
+	Lines  []models.PurchaseOrderLine `json:"lines" validate:"omitempty,min=1,dive"`
+	Note   *string                    `json:"note"`
+}
+
+// ReceiveRequest is a delivery against a purchase order.
+type ReceiveRequest struct {
+	Lines []models.DeliveryLine `json:"lines" validate:"required,min=1,dive"`
+}
+
+// GetPurchaseOrders lists purchase orders, ?status= and ?supplier_id= filter
+// them.
+func GetPurchaseOrders() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		filter := bson.M{}
+		if status := ctx.Query("status"); status != "" {
+			filter["status"] = status
+		}
+		if supplierId := ctx.Query("supplier_id"); supplierId != "" {
+			filter["supplier_id"] = supplierId
+		}
+
+		result, err := purchaseOrderCollection.Find(c, filter, options.Find().SetSort(bson.D{{"created_at", -1}}))
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while listing Purchase Orders"})
+			return
+		}
+
+		var allPurchaseOrders []bson.M
+		if err = result.All(c, &allPurchaseOrders); err != nil {
+			log.Println(err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while listing Purchase Orders"})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, allPurchaseOrders)
+	}
+}
+
+func GetPurchaseOrder() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var purchaseOrder models.PurchaseOrder
+		if err := purchaseOrderCollection.FindOne(c, bson.M{"purchase_order_id": ctx.Param("purchase_order_id")}).Decode(&purchaseOrder); err != nil {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Purchase Order was not found"})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, purchaseOrder)
+	}
+}
+
+// CreatePurchaseOrder creates a DRAFT purchase order, or places it straight
+// away when its status is ORDERED.
+func CreatePurchaseOrder() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var purchaseOrder models.PurchaseOrder
+
+		if err := ctx.BindJSON(&purchaseOrder); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validationErr := validate.Struct(purchaseOrder)
+		if validationErr != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		if err := checkSupplier(c, *purchaseOrder.Supplier_id); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		total, err := purchaseOrderTotal(c, purchaseOrder.Lines)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		for i := range purchaseOrder.Lines {
+			purchaseOrder.Lines[i].Received = 0
+		}
+
+		status := models.PurchaseOrderDraft
+		if purchaseOrder.Status != nil {
+			status = *purchaseOrder.Status
+		}
+		purchaseOrder.Status = &status
+		purchaseOrder.Total = total
+		purchaseOrder.Deliveries = nil
+		purchaseOrder.Created_by = ctx.GetString("user_id")
+		purchaseOrder.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		purchaseOrder.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		purchaseOrder.Version = 0
+		purchaseOrder.Ordered_at = nil
+		if status == models.PurchaseOrderOrdered {
+			purchaseOrder.Ordered_at = &purchaseOrder.Created_at
+		}
+		purchaseOrder.ID = primitive.NewObjectID()
+		purchaseOrder.Purchase_order_id = purchaseOrder.ID.Hex()
+
+		result, insertErr := purchaseOrderCollection.InsertOne(c, purchaseOrder)
+		if insertErr != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Purchase Order was not created"})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, result)
+	}
+}
+
+// UpdatePurchaseOrder changes a purchase order. A DRAFT can be edited,
+// placed or cancelled, an order that was placed can only be cancelled.
+func UpdatePurchaseOrder() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var request PurchaseOrderUpdate
+		var purchaseOrder models.PurchaseOrder
+		purchaseOrderId := ctx.Param("purchase_order_id")
+
+		if err := ctx.BindJSON(&request); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if validationErr := validate.Struct(request); validationErr != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		if err := purchaseOrderCollection.FindOne(c, bson.M{"purchase_order_id": purchaseOrderId}).Decode(&purchaseOrder); err != nil {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Purchase Order was not found"})
+			return
+		}
+
+		current := *purchaseOrder.Status
+		if current == models.PurchaseOrderReceived || current == models.PurchaseOrderCancelled {
+			ctx.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Purchase Order is %s and can no longer be changed", current)})
+			return
+		}
+		if request.Lines != nil && current != models.PurchaseOrderDraft {
+			ctx.JSON(http.StatusConflict, gin.H{"error": "Only the lines of a DRAFT Purchase Order can be changed"})
+			return
+		}
+		if request.Status != nil && *request.Status == models.PurchaseOrderOrdered && current != models.PurchaseOrderDraft {
+			ctx.JSON(http.StatusConflict, gin.H{"error": "Purchase Order has already been placed"})
+			return
+		}
+
+		var updateObj primitive.D
+
+		if request.Lines != nil {
+			total, err := purchaseOrderTotal(c, request.Lines)
+			if err != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			for i := range request.Lines {
+				request.Lines[i].Received = 0
+			}
+			updateObj = append(updateObj, bson.E{"lines", request.Lines})
+			updateObj = append(updateObj, bson.E{"total", total})
+		}
+
+		if request.Note != nil {
+			updateObj = append(updateObj, bson.E{"note", *request.Note})
+		}
+
+		updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		if request.Status != nil {
+			updateObj = append(updateObj, bson.E{"status", *request.Status})
+			if *request.Status == models.PurchaseOrderOrdered {
+				updateObj = append(updateObj, bson.E{"ordered_at", updatedAt})
+			}
+		}
+		updateObj = append(updateObj, bson.E{"updated_at", updatedAt})
+
+		result, err := purchaseOrderCollection.UpdateOne(
+			c,
+			bson.M{"purchase_order_id": purchaseOrderId, "version": versionFilter(purchaseOrder.Version)},
+			bson.D{
+				{"$set", updateObj},
+				{"$inc", bson.D{{"version", 1}}},
+			},
+		)
+		if err != nil {
+			msg := fmt.Sprintf("Purchase Order update failed")
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			return
+		}
+		if result.MatchedCount == 0 {
+			ctx.JSON(http.StatusConflict, gin.H{"error": "Purchase Order was changed by someone else, please retry"})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, result)
+	}
+}
+
+// ReceivePurchaseOrder records a delivery against a placed purchase order.
+// What arrived is added to stock and its unit cost becomes the last cost of
+// the ingredient. The order is RECEIVED once every line has fully arrived.
+func ReceivePurchaseOrder() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var request ReceiveRequest
+		var purchaseOrder models.PurchaseOrder
+		purchaseOrderId := ctx.Param("purchase_order_id")
+
+		if err := ctx.BindJSON(&request); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if validationErr := validate.Struct(request); validationErr != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		if err := purchaseOrderCollection.FindOne(c, bson.M{"purchase_order_id": purchaseOrderId}).Decode(&purchaseOrder); err != nil {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Purchase Order was not found"})
+			return
+		}
+
+		status := *purchaseOrder.Status
+		if status != models.PurchaseOrderOrdered && status != models.PurchaseOrderPartiallyReceived {
+			ctx.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Purchase Order is %s, only placed orders can be received", status)})
+			return
+		}
+
+		delivery, err := receiveDelivery(&purchaseOrder, request.Lines, ctx.GetString("user_id"))
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		session, err := database.Client.StartSession()
+		if err != nil {
+			log.Println(err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Delivery was not received"})
+			return
+		}
+		defer session.EndSession(c)
+
+		_, err = session.WithTransaction(c, func(sc mongo.SessionContext) (interface{}, error) {
+			result, err := purchaseOrderCollection.UpdateOne(
+				sc,
+				bson.M{"purchase_order_id": purchaseOrderId, "version": versionFilter(purchaseOrder.Version)},
+				bson.D{
+					{"$set", bson.D{
+						{"lines", purchaseOrder.Lines},
+						{"status", purchaseOrder.Status},
+						{"updated_at", delivery.Received_at},
+					}},
+					{"$push", bson.D{{"deliveries", delivery}}},
+					{"$inc", bson.D{{"version", 1}}},
+				},
+			)
+			if err != nil {
+				return nil, err
+			}
+			if result.MatchedCount == 0 {
+				return nil, errPurchaseOrderChanged
+			}
+
+			received := make([]models.RecipeItem, 0, len(delivery.Lines))
+			for _, line := range delivery.Lines {
+				received = append(received, models.RecipeItem{Ingredient_id: line.Ingredient_id, Quantity: line.Quantity})
+				_, err := ingredientCollection.UpdateOne(
+					sc,
+					bson.M{"ingredient_id": line.Ingredient_id},
+					bson.D{
+						{"$set", bson.D{{"last_cost", line.Unit_cost}}},
+					},
+				)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			return nil, adjustStock(sc, received, true)
+		})
+		if err == errPurchaseOrderChanged {
+			ctx.JSON(http.StatusConflict, gin.H{"error": "Purchase Order was changed by someone else, please retry"})
+			return
+		}
+		if err != nil {
+			log.Println(err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Delivery was not received"})
+			return
+		}
+
+		purchaseOrder.Updated_at = delivery.Received_at
+		purchaseOrder.Version++
+		purchaseOrder.Deliveries = append(purchaseOrder.Deliveries, delivery)
+		ctx.JSON(http.StatusOK, purchaseOrder)
+	}
+}
+
+// purchaseOrderTotal checks the lines of a purchase order and totals them.
+// Every line must be a different ingredient and cost something, all in the
+// same currency.
+func purchaseOrderTotal(c context.Context, lines []models.PurchaseOrderLine) (models.Money, error) {
+	var total models.Money
+	recipe := make([]models.RecipeItem, 0, len(lines))
+	seen := map[string]bool{}
+	for _, line := range lines {
+		if seen[line.Ingredient_id] {
+			return total, fmt.Errorf("Ingredient %s is on more than one line", line.Ingredient_id)
+		}
+		seen[line.Ingredient_id] = true
+
+		if !line.Unit_cost.IsPositive() {
+			return total, fmt.Errorf("Unit cost of ingredient %s must be positive", line.Ingredient_id)
+		}
+
+		total = total.Add(line.Unit_cost.Times(line.Quantity))
+		recipe = append(recipe, models.RecipeItem{Ingredient_id: line.Ingredient_id, Quantity: line.Quantity})
+	}
+
+	return total, validateRecipes(c, recipe, nil)
+}
+
+// receiveDelivery applies a delivery to the lines of a purchase order and
+// works out its new status.
+func receiveDelivery(purchaseOrder *models.PurchaseOrder, lines []models.DeliveryLine, userId string) (models.Delivery, error) {
+	var delivery models.Delivery
+
+	index := map[string]int{}
+	for i, line := range purchaseOrder.Lines {
+		index[line.Ingredient_id] = i
+	}
+
+	for _, line := range lines {
+		i, ok := index[line.Ingredient_id]
+		if !ok {
+			return delivery, fmt.Errorf("Ingredient %s is not on this Purchase Order", line.Ingredient_id)
+		}
+		ordered := &purchaseOrder.Lines[i]
+
+		if line.Unit_cost == nil {
+			line.Unit_cost = ordered.Unit_cost
+		}
+		if !line.Unit_cost.IsPositive() || line.Unit_cost.Currency != ordered.Unit_cost.Currency {
+			return delivery, fmt.Errorf("Unit cost of ingredient %s must be positive and in %s", line.Ingredient_id, ordered.Unit_cost.Currency)
+		}
+
+		ordered.Received += line.Quantity
+		delivery.Cost = delivery.Cost.Add(line.Unit_cost.Times(line.Quantity))
+		delivery.Lines = append(delivery.Lines, line)
+	}
+
+	status := models.PurchaseOrderReceived
+	for _, line := range purchaseOrder.Lines {
+		if line.Received < line.Quantity {
+			status = models.PurchaseOrderPartiallyReceived
+			break
+		}
+	}
+	purchaseOrder.Status = &status
+
+	delivery.Delivery_id = primitive.NewObjectID().Hex()
+	delivery.Received_by = userId
+	delivery.Received_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+	return delivery, nil
+}
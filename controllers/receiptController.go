@@ -0,0 +1,162 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/models"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/receipts"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// GetInvoiceReceipt renders the receipt of an invoice as text, escpos or
+// pdf, picked with the format query parameter.
+func GetInvoiceReceipt() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var invoice models.Invoice
+		if err := invoiceCollection.FindOne(c, bson.M{"invoice_id": ctx.Param("invoice_id")}).Decode(&invoice); err != nil {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Invoice was not found"})
+			return
+		}
+
+		receipt, err := invoiceReceipt(c, invoice)
+		if err != nil {
+			log.Println(err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while building the Receipt"})
+			return
+		}
+
+		body, contentType, err := receipts.Render(receipt, ctx.Query("format"))
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if ctx.Query("format") == "pdf" {
+			ctx.Header("Content-Disposition", fmt.Sprintf("inline; filename=receipt-%s.pdf", invoice.Invoice_id))
+		}
+		ctx.Data(http.StatusOK, contentType, body)
+	}
+}
+
+// invoiceReceipt gathers what goes on the receipt of an invoice. Invoices
+// from before lines were stored are itemised from their order instead.
+func invoiceReceipt(c context.Context, invoice models.Invoice) (receipts.Receipt, error) {
+	receipt := receipts.New()
+	receipt.Invoice_id = invoice.Invoice_id
+	receipt.Date = invoice.Created_at
+
+	allOrderedItems, err := ItemsByOrder(invoice.Order_id)
+	if err != nil {
+		return receipt, err
+	}
+	if len(allOrderedItems) > 0 && allOrderedItems[0]["table_number"] != nil {
+		receipt.Table_number = fmt.Sprint(allOrderedItems[0]["table_number"])
+	}
+
+	if invoice.Split != nil {
+		receipt.Split = fmt.Sprintf("%d of %d", invoice.Split.Part, invoice.Split.Parts)
+		if invoice.Split.Type == models.SplitEven {
+			receipt.Split += ", shared evenly"
+		}
+	}
+
+	if len(invoice.Lines) == 0 {
+		return legacyReceipt(receipt, allOrderedItems)
+	}
+
+	taxes := map[float64]models.Money{}
+	for _, line := range invoice.Lines {
+		receipt.Lines = append(receipt.Lines, receipts.Line{
+			Name:       line.Name,
+			Quantity:   line.Quantity,
+			Portion:    line.Portion,
+			Modifiers:  line.Modifiers,
+			Components: line.Components,
+			Amount:     line.Gross,
+			Voided:     line.Voided,
+		})
+		if line.Tax.IsPositive() {
+			taxes[line.Tax_rate] = taxes[line.Tax_rate].Add(line.Tax)
+		}
+	}
+	for rate, amount := range taxes {
+		receipt.Taxes = append(receipt.Taxes, receipts.Tax{Rate: rate, Amount: amount})
+	}
+	sort.Slice(receipt.Taxes, func(i, j int) bool { return receipt.Taxes[i].Rate < receipt.Taxes[j].Rate })
+
+	// An even split prints every line but only its share of the tax.
+	if invoice.Split != nil && invoice.Split.Type == models.SplitEven {
+		receipt.Taxes = []receipts.Tax{{Amount: invoice.Tax_total}}
+	}
+
+	receipt.Subtotal = invoice.Subtotal
+	receipt.Discount = invoice.Discount_total
+	receipt.Service_charge = invoice.Service_charge
+	if invoice.Tip != nil {
+		receipt.Tip = *invoice.Tip
+	}
+	receipt.Total = invoice.Grand_total
+	receipt.Refunded = invoice.Amount_refunded
+	receipt.Balance_due = invoiceBalance(invoice)
+
+	result, err := paymentCollection.Find(c, bson.M{"invoice_id": invoice.Invoice_id, "status": bson.M{"$in": bson.A{models.TenderSettled, nil}}})
+	if err != nil {
+		return receipt, err
+	}
+	var allPayments []models.Payment
+	if err = result.All(c, &allPayments); err != nil {
+		return receipt, err
+	}
+	sort.Slice(allPayments, func(i, j int) bool { return allPayments[i].Created_at.Before(allPayments[j].Created_at) })
+	for _, payment := range allPayments {
+		receipt.Payments = append(receipt.Payments, receipts.Payment{Method: *payment.Method, Amount: *payment.Amount, Change: payment.Change})
+	}
+
+	return receipt, nil
+}
+
+func legacyReceipt(receipt receipts.Receipt, allOrderedItems []bson.M) (receipts.Receipt, error) {
+	if len(allOrderedItems) == 0 {
+		return receipt, nil
+	}
+
+	var order struct {
+		Payment_due models.Money `bson:"payment_due"`
+		Order_items []struct {
+			Food_name  string       `bson:"food_name"`
+			Quantity   int          `bson:"quantity"`
+			Portion    string       `bson:"portion"`
+			Line_total models.Money `bson:"line_total"`
+		} `bson:"order_items"`
+	}
+	data, err := bson.Marshal(allOrderedItems[0])
+	if err != nil {
+		return receipt, err
+	}
+	if err := bson.Unmarshal(data, &order); err != nil {
+		return receipt, err
+	}
+
+	for _, orderItem := range order.Order_items {
+		receipt.Lines = append(receipt.Lines, receipts.Line{
+			Name:     orderItem.Food_name,
+			Quantity: orderItem.Quantity,
+			Portion:  orderItem.Portion,
+			Amount:   orderItem.Line_total,
+		})
+	}
+	receipt.Subtotal = order.Payment_due
+	receipt.Total = order.Payment_due
+	receipt.Balance_due = order.Payment_due
+
+	return receipt, nil
+}
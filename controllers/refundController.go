@@ -0,0 +1,454 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/database"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/helpers"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/models"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/payments"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var refundCollection *mongo.Collection = database.OpenCollection(database.Client, "refund")
+
+// RefundRequest refunds Amount, or everything still refundable when it is
+// not set. Payment_id refunds against one payment only, otherwise the most
+// recent payments are refunded first.
+type RefundRequest struct {
+	Amount      *models.Money
+	Payment_id  string
+	Reason_code *string
+	Note        string
+}
+
+// VoidRequest voids one line of an invoice. Voids worth more than
+// VOID_APPROVAL_THRESHOLD need a manager, either the signed in user or one
+// approving with their credentials.
+type VoidRequest struct {
+	Reason_code *string
+	Note        string
+	Approval    *ManagerApproval
+}
+
+type ManagerApproval struct {
+	Email    string
+	Password string
+}
+
+func GetRefunds() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		result, err := refundCollection.Find(c, bson.M{"invoice_id": ctx.Param("invoice_id")})
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while listing Refunds"})
+			return
+		}
+
+		var allRefunds []bson.M
+		if err = result.All(c, &allRefunds); err != nil {
+			log.Println(err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while listing Refunds"})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, allRefunds)
+	}
+}
+
+// CreateRefund gives back some or all of what was paid on an invoice.
+func CreateRefund() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var request RefundRequest
+		var invoice models.Invoice
+
+		if err := ctx.BindJSON(&request); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := invoiceCollection.FindOne(c, bson.M{"invoice_id": ctx.Param("invoice_id")}).Decode(&invoice); err != nil {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Invoice was not found"})
+			return
+		}
+
+		refundable := invoice.Amount_paid.Sub(invoice.Amount_refunded)
+		if !refundable.IsPositive() {
+			ctx.JSON(http.StatusConflict, gin.H{"error": "Invoice has nothing left to refund"})
+			return
+		}
+
+		amount := refundable
+		if request.Amount != nil {
+			amount = *request.Amount
+		}
+		if !amount.IsPositive() || amount.Currency != refundable.Currency || amount.Amount > refundable.Amount {
+			msg := fmt.Sprintf("Refund must be a positive amount in %s of at most %s", refundable.Currency, refundable)
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+
+		refund := newRefund(invoice, models.RefundTypeRefund, request.Reason_code, request.Note, ctx.GetString("user_id"))
+		refund.Amount = amount
+		if validationErr := validate.Struct(refund); validationErr != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		saveRefund(ctx, c, invoice, invoice, refund, request.Payment_id)
+	}
+}
+
+// VoidInvoiceLine stops charging for one line of an invoice. Whatever the
+// void leaves overpaid is refunded.
+func VoidInvoiceLine() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var request VoidRequest
+		var invoice models.Invoice
+		orderItemId := ctx.Param("order_item_id")
+
+		if err := ctx.BindJSON(&request); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := invoiceCollection.FindOne(c, bson.M{"invoice_id": ctx.Param("invoice_id")}).Decode(&invoice); err != nil {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Invoice was not found"})
+			return
+		}
+
+		if invoice.Split != nil && invoice.Split.Type == models.SplitEven {
+			ctx.JSON(http.StatusConflict, gin.H{"error": "Lines of an even split can not be voided, refund instead"})
+			return
+		}
+		if invoice.Payment_status != nil && *invoice.Payment_status == models.PaymentRefunded {
+			ctx.JSON(http.StatusConflict, gin.H{"error": "Invoice has been refunded"})
+			return
+		}
+
+		voided := invoice
+		voided.Lines = append([]models.InvoiceLine(nil), invoice.Lines...)
+		found := false
+		for i := range voided.Lines {
+			if voided.Lines[i].Order_item_id == orderItemId && !voided.Lines[i].Voided {
+				voided.Lines[i].Voided = true
+				if request.Reason_code != nil {
+					voided.Lines[i].Void_reason = *request.Reason_code
+				}
+				found = true
+			}
+		}
+		if !found {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Order item is not a line of this Invoice, or is already voided"})
+			return
+		}
+
+		if err := totalInvoice(&voided); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		refund := newRefund(invoice, models.RefundTypeVoid, request.Reason_code, request.Note, ctx.GetString("user_id"))
+		refund.Order_item_id = orderItemId
+		refund.Amount = invoice.Grand_total.Sub(voided.Grand_total)
+		if validationErr := validate.Struct(refund); validationErr != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		if threshold := voidApprovalThreshold(refund.Amount.Currency); refund.Amount.Amount > threshold.Amount {
+			approvedBy, err := managerApproval(ctx, c, request.Approval)
+			if err != nil {
+				msg := fmt.Sprintf("Voids over %s need a manager's approval: %s", threshold, err)
+				ctx.JSON(http.StatusForbidden, gin.H{"error": msg})
+				return
+			}
+			refund.Approved_by = approvedBy
+		}
+
+		saveRefund(ctx, c, invoice, voided, refund, "")
+	}
+}
+
+func newRefund(invoice models.Invoice, refundType string, reasonCode *string, note string, userId string) models.Refund {
+	var refund models.Refund
+
+	refund.ID = primitive.NewObjectID()
+	refund.Refund_id = refund.ID.Hex()
+	refund.Invoice_id = invoice.Invoice_id
+	refund.Type = refundType
+	refund.Reason_code = reasonCode
+	refund.Note = note
+	refund.Created_by = userId
+	refund.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+	return refund
+}
+
+// saveRefund records a refund or void against found, the invoice as it was
+// read, and updated, the invoice with any voided line re-totalled. Whatever
+// updated has been overpaid is given back on its payments. The invoice,
+// payments and refund are written in one transaction before any card is
+// refunded, so money only goes back for a refund that is on record.
+func saveRefund(ctx *gin.Context, c context.Context, found models.Invoice, updated models.Invoice, refund models.Refund, paymentId string) {
+	giveBack := refund.Amount
+	if refund.Type == models.RefundTypeVoid {
+		giveBack = updated.Amount_paid.Sub(updated.Amount_refunded).Sub(updated.Grand_total)
+	}
+
+	var refundedPayments []models.Payment
+	if giveBack.IsPositive() {
+		var err error
+		refund.Tenders, refundedPayments, err = planRefundTenders(c, found.Invoice_id, giveBack, paymentId)
+		if err != nil {
+			ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		updated.Amount_refunded = updated.Amount_refunded.Add(giveBack)
+	}
+
+	updated.Balance_due = invoiceBalance(updated)
+	status := invoicePaymentStatus(updated)
+	// A void that leaves nothing to pay and nothing to give back settles the
+	// invoice, just as the last payment would have.
+	settled := !giveBack.IsPositive() && invoiceBalance(found).IsPositive() && !updated.Balance_due.IsPositive()
+	if settled {
+		status = models.PaymentPaid
+	}
+	updated.Payment_status = &status
+	updated.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+	session, err := database.Client.StartSession()
+	if err != nil {
+		log.Println(err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Refund was not recorded"})
+		return
+	}
+	defer session.EndSession(c)
+
+	_, err = session.WithTransaction(c, func(sc mongo.SessionContext) (interface{}, error) {
+		result, err := invoiceCollection.UpdateOne(
+			sc,
+			invoiceMoneyFilter(found),
+			bson.D{
+				{"$set", bson.D{
+					{"lines", updated.Lines},
+					{"subtotal", updated.Subtotal},
+					{"discount_total", updated.Discount_total},
+					{"tax_total", updated.Tax_total},
+					{"service_charge", updated.Service_charge},
+					{"grand_total", updated.Grand_total},
+					{"amount_refunded", updated.Amount_refunded},
+					{"balance_due", updated.Balance_due},
+					{"payment_status", updated.Payment_status},
+					{"updated_at", updated.Updated_at},
+				}},
+			},
+		)
+		if err != nil {
+			return nil, err
+		}
+		if result.MatchedCount == 0 {
+			return nil, errInvoiceChanged
+		}
+		if settled {
+			if err := onInvoicePaid(sc, updated, refund.Created_by); err != nil {
+				return nil, err
+			}
+		}
+
+		for _, payment := range refundedPayments {
+			_, err := paymentCollection.UpdateOne(
+				sc,
+				bson.M{"payment_id": payment.Payment_id},
+				bson.D{
+					{"$set", bson.D{{"refunded", payment.Refunded}}},
+				},
+			)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		// A voided item is not sold, what it took from stock goes back.
+		if refund.Order_item_id != "" {
+			if err := restoreOrderItemStock(sc, refund.Order_item_id); err != nil {
+				return nil, err
+			}
+		}
+
+		return refundCollection.InsertOne(sc, refund)
+	})
+	if err == errInvoiceChanged {
+		ctx.JSON(http.StatusConflict, gin.H{"error": "Invoice was changed by someone else, please retry"})
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Refund was not recorded"})
+		return
+	}
+
+	refund.Tenders = refundCardTenders(c, refund)
+
+	for _, tender := range refund.Tenders {
+		if tender.Status == models.TenderFailed {
+			ctx.JSON(http.StatusBadGateway, gin.H{"error": "The refund is recorded but a card refund failed, refund it by hand", "refund": refund})
+			return
+		}
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"refund": refund, "invoice": updated})
+}
+
+// planRefundTenders spreads amount over the settled payments of an invoice,
+// the most recent first. It returns the tenders and the payments with their
+// new refunded amounts.
+func planRefundTenders(c context.Context, invoiceId string, amount models.Money, paymentId string) ([]models.RefundTender, []models.Payment, error) {
+	filter := bson.M{"invoice_id": invoiceId, "status": bson.M{"$in": bson.A{models.TenderSettled, nil}}}
+	if paymentId != "" {
+		filter["payment_id"] = paymentId
+	}
+
+	result, err := paymentCollection.Find(c, filter)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var allPayments []models.Payment
+	if err = result.All(c, &allPayments); err != nil {
+		return nil, nil, err
+	}
+	sort.Slice(allPayments, func(i, j int) bool {
+		return allPayments[i].Created_at.After(allPayments[j].Created_at)
+	})
+
+	var tenders []models.RefundTender
+	var refunded []models.Payment
+	left := amount
+	for _, payment := range allPayments {
+		if !left.IsPositive() {
+			break
+		}
+
+		share := payment.Applied.Sub(payment.Refunded).Min(left)
+		if !share.IsPositive() {
+			continue
+		}
+
+		payment.Refunded = payment.Refunded.Add(share)
+		left = left.Sub(share)
+		refunded = append(refunded, payment)
+		tenders = append(tenders, models.RefundTender{
+			Payment_id:     payment.Payment_id,
+			Method:         *payment.Method,
+			Amount:         share,
+			Transaction_id: payment.Transaction_id,
+			Status:         models.TenderPending,
+		})
+	}
+
+	if left.IsPositive() {
+		return nil, nil, fmt.Errorf("Payments of this Invoice only cover %s of the %s to refund", amount.Sub(left), amount)
+	}
+
+	return tenders, refunded, nil
+}
+
+// refundCardTenders runs the card tenders of a recorded refund through their
+// payment provider and records how each went. Cash is given back from the
+// drawer and is settled straight away.
+func refundCardTenders(c context.Context, refund models.Refund) []models.RefundTender {
+	tenders := append([]models.RefundTender(nil), refund.Tenders...)
+
+	for i := range tenders {
+		tender := &tenders[i]
+		tender.Status = models.TenderSettled
+		if tender.Method != models.TenderCard {
+			continue
+		}
+
+		var payment models.Payment
+		err := paymentCollection.FindOne(c, bson.M{"payment_id": tender.Payment_id}).Decode(&payment)
+		if err == nil {
+			provider, ok := payments.Lookup(payment.Provider)
+			if !ok {
+				err = fmt.Errorf("payment provider %q is not registered", payment.Provider)
+			} else {
+				_, err = provider.Refund(c, tender.Transaction_id, tender.Amount)
+			}
+		}
+		if err != nil {
+			log.Println(err)
+			tender.Status = models.TenderFailed
+		}
+	}
+
+	_, err := refundCollection.UpdateOne(
+		c,
+		bson.M{"refund_id": refund.Refund_id},
+		bson.D{
+			{"$set", bson.D{{"tenders", tenders}}},
+		},
+	)
+	if err != nil {
+		log.Println(err)
+	}
+
+	return tenders
+}
+
+// voidApprovalThreshold is VOID_APPROVAL_THRESHOLD in major units, 20 when
+// it is not set.
+func voidApprovalThreshold(currency string) models.Money {
+	threshold := 20.0
+	if value, err := strconv.ParseFloat(os.Getenv("VOID_APPROVAL_THRESHOLD"), 64); err == nil {
+		threshold = value
+	}
+
+	return models.MoneyFromFloat(threshold, currency)
+}
+
+// managerApproval is the user id of the manager approving an action. A
+// signed in manager approves their own actions, anyone else needs a manager
+// to enter their credentials.
+func managerApproval(ctx *gin.Context, c context.Context, approval *ManagerApproval) (string, error) {
+	if helpers.CheckUserRole(ctx, models.RoleManager) == nil {
+		return ctx.GetString("user_id"), nil
+	}
+	if approval == nil {
+		return "", errors.New("approval is missing")
+	}
+
+	var manager models.User
+	if err := userCollection.FindOne(c, bson.M{"email": approval.Email}).Decode(&manager); err != nil {
+		return "", errors.New("Email or Password is incorrect")
+	}
+	if ok, msg := VerifyPassword(approval.Password, *manager.Password); !ok {
+		return "", errors.New(msg)
+	}
+	if role := userRole(manager); role != models.RoleManager && role != models.RoleAdmin {
+		return "", errors.New("approver is not a manager")
+	}
+
+	return manager.User_id, nil
+}
@@ -0,0 +1,270 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SplitBillRequest splits the unbilled items of an order into several
+// invoices. ITEM splits take the order item ids of each invoice in Groups,
+// SEAT splits bill every seat separately and EVEN splits share the whole
+// bill between Parts guests. Discounts and tips of item and seat splits are
+// set on each invoice afterwards, an even split takes them up front.
+type SplitBillRequest struct {
+	Order_id            string           `json:"order_id" validate:"required"`
+	Type                string           `json:"type" validate:"required,eq=ITEM|eq=SEAT|eq=EVEN"`
+	Groups              [][]string       `json:"groups" validate:"required_if=Type ITEM,omitempty,dive,min=1"`
+	Parts               int              `json:"parts" validate:"required_if=Type EVEN,omitempty,min=2,max=50"`
+	Payment_method      *string          `json:"payment_method" validate:"omitempty,eq=CARD|eq=CASH"`
+	Order_discount      *models.Discount `json:"order_discount"`
+	Service_charge_rate *float64         `json:"service_charge_rate" validate:"omitempty,gte=0,lte=100"`
+	Tip                 *models.Money    `json:"tip"`
+}
+
+// SplitInvoice creates one invoice per group of a split bill. Every item is
+// billed at most once, items left out of an item split stay unbilled.
+func SplitInvoice() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var request SplitBillRequest
+		if err := ctx.BindJSON(&request); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if validationErr := validate.Struct(request); validationErr != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		if request.Type != models.SplitEven && (request.Order_discount != nil || request.Tip != nil) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Discounts and tips of an item or seat split are set on each Invoice"})
+			return
+		}
+
+		var order models.Order
+		if err := orderCollection.FindOne(c, bson.M{"order_id": request.Order_id}).Decode(&order); err != nil {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Order was not found"})
+			return
+		}
+		if orderIsClosed(order) {
+			ctx.JSON(http.StatusConflict, gin.H{"error": "Order is closed, its bill can no longer be split"})
+			return
+		}
+
+		orderItems, err := unbilledOrderItems(c, order.Order_id)
+		if err != nil {
+			log.Println(err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while listing the Order items"})
+			return
+		}
+		if len(orderItems) == 0 {
+			ctx.JSON(http.StatusConflict, gin.H{"error": "Every item of this Order has already been billed"})
+			return
+		}
+
+		var groups [][]models.OrderItem
+		var seats []int
+		switch request.Type {
+		case models.SplitByItem:
+			groups, err = splitByItem(orderItems, request.Groups)
+		case models.SplitBySeat:
+			groups, seats, err = splitBySeat(orderItems)
+		default:
+			groups = [][]models.OrderItem{orderItems}
+		}
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		splitId := primitive.NewObjectID().Hex()
+		var invoices []models.Invoice
+		billed := map[string][]models.OrderItem{}
+
+		if request.Type == models.SplitEven {
+			bill := newInvoice(order.Order_id, request.Payment_method)
+			bill.Order_discount = request.Order_discount
+			bill.Service_charge_rate = request.Service_charge_rate
+			bill.Tip = request.Tip
+			if bill.Lines, err = buildInvoiceLines(c, orderItems); err != nil {
+				log.Println(err)
+				ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while pricing the Order"})
+				return
+			}
+			if err := totalInvoice(&bill); err != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			invoices = splitEvenly(bill, request.Parts)
+			billed[splitId] = orderItems
+		} else {
+			for i, group := range groups {
+				invoice := newInvoice(order.Order_id, request.Payment_method)
+				invoice.Service_charge_rate = request.Service_charge_rate
+				if invoice.Lines, err = buildInvoiceLines(c, group); err != nil {
+					log.Println(err)
+					ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while pricing the Order"})
+					return
+				}
+				if err := totalInvoice(&invoice); err != nil {
+					ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+					return
+				}
+
+				invoice.Split = &models.InvoiceSplit{Type: request.Type, Part: i + 1, Parts: len(groups)}
+				if request.Type == models.SplitBySeat {
+					invoice.Split.Seat = &seats[i]
+				}
+				invoices = append(invoices, invoice)
+				billed[invoice.Invoice_id] = group
+			}
+		}
+
+		for i := range invoices {
+			invoices[i].Split.Split_id = splitId
+		}
+
+		err = insertInvoices(c, invoices, billed)
+		if err == errAlreadyBilled {
+			ctx.JSON(http.StatusConflict, gin.H{"error": "Items of this Order were billed by someone else, please retry"})
+			return
+		}
+		if err != nil {
+			log.Println(err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Split bill was not created"})
+			return
+		}
+
+		if err := setTableStatus(c, order.Table_id, models.TableAwaitingBill, ""); err != nil {
+			log.Println(err)
+		}
+
+		ctx.JSON(http.StatusOK, invoices)
+	}
+}
+
+func newInvoice(orderId string, paymentMethod *string) models.Invoice {
+	var invoice models.Invoice
+
+	status := models.PaymentPending
+	invoice.Order_id = orderId
+	invoice.Payment_method = paymentMethod
+	invoice.Payment_status = &status
+	invoice.Payment_due_date, _ = time.Parse(time.RFC3339, time.Now().AddDate(0, 0, 1).Format(time.RFC3339))
+	invoice.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	invoice.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	invoice.ID = primitive.NewObjectID()
+	invoice.Invoice_id = invoice.ID.Hex()
+
+	return invoice
+}
+
+// splitByItem resolves the order item ids of each group. An item can only
+// be in one group.
+func splitByItem(orderItems []models.OrderItem, orderItemIds [][]string) ([][]models.OrderItem, error) {
+	unbilled := map[string]models.OrderItem{}
+	for _, orderItem := range orderItems {
+		unbilled[orderItem.Order_item_id] = orderItem
+	}
+
+	groups := make([][]models.OrderItem, 0, len(orderItemIds))
+	seen := map[string]bool{}
+	for _, ids := range orderItemIds {
+		var group []models.OrderItem
+		for _, orderItemId := range ids {
+			orderItem, ok := unbilled[orderItemId]
+			if !ok {
+				return nil, fmt.Errorf("Order item %s is not an unbilled item of this Order", orderItemId)
+			}
+			if seen[orderItemId] {
+				return nil, fmt.Errorf("Order item %s is in more than one group", orderItemId)
+			}
+			seen[orderItemId] = true
+			group = append(group, orderItem)
+		}
+		groups = append(groups, group)
+	}
+
+	return groups, nil
+}
+
+// splitBySeat groups the items by seat, in seat order.
+func splitBySeat(orderItems []models.OrderItem) ([][]models.OrderItem, []int, error) {
+	bySeat := map[int][]models.OrderItem{}
+	for _, orderItem := range orderItems {
+		if orderItem.Seat == nil {
+			return nil, nil, fmt.Errorf("Order item %s has no seat, set one or split by item", orderItem.Order_item_id)
+		}
+		bySeat[*orderItem.Seat] = append(bySeat[*orderItem.Seat], orderItem)
+	}
+
+	seats := make([]int, 0, len(bySeat))
+	for seat := range bySeat {
+		seats = append(seats, seat)
+	}
+	sort.Ints(seats)
+
+	groups := make([][]models.OrderItem, 0, len(seats))
+	for _, seat := range seats {
+		groups = append(groups, bySeat[seat])
+	}
+
+	return groups, seats, nil
+}
+
+// splitEvenly shares a totalled bill between parts invoices. The grand
+// total is allocated once, so no part pays more than a minor unit over any
+// other. Discount, tax, service charge and tip are allocated too and each
+// subtotal is what is left of its part, so every amount adds up to the bill
+// exactly and each grand total is the sum of its own parts.
+func splitEvenly(bill models.Invoice, parts int) []models.Invoice {
+	weights := make([]int64, parts)
+	for i := range weights {
+		weights[i] = 1
+	}
+
+	grandTotals := bill.Grand_total.Allocate(weights)
+	discounts := bill.Discount_total.Allocate(weights)
+	taxes := bill.Tax_total.Allocate(weights)
+	serviceCharges := bill.Service_charge.Allocate(weights)
+	tips := make([]models.Money, parts)
+	if bill.Tip != nil {
+		tips = bill.Tip.Allocate(weights)
+	}
+
+	invoices := make([]models.Invoice, 0, parts)
+	for i := 0; i < parts; i++ {
+		invoice := bill
+		if i > 0 {
+			invoice.ID = primitive.NewObjectID()
+			invoice.Invoice_id = invoice.ID.Hex()
+		}
+		if bill.Tip != nil {
+			invoice.Tip = &tips[i]
+		}
+
+		invoice.Subtotal = grandTotals[i].Add(discounts[i]).Sub(taxes[i]).Sub(serviceCharges[i]).Sub(tips[i])
+		invoice.Discount_total = discounts[i]
+		invoice.Tax_total = taxes[i]
+		invoice.Service_charge = serviceCharges[i]
+		invoice.Grand_total = grandTotals[i]
+		invoice.Balance_due = invoice.Grand_total
+		invoice.Split = &models.InvoiceSplit{Type: models.SplitEven, Part: i + 1, Parts: parts}
+		invoices = append(invoices, invoice)
+	}
+
+	return invoices
+}
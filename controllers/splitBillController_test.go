@@ -0,0 +1,193 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/models"
+)
+
+func TestSplitEvenly(t *testing.T) {
+	usd := func(amount int64) models.Money { return models.NewMoney(amount, "USD") }
+	tip := usd(100)
+
+	tests := []struct {
+		name  string
+		bill  models.Invoice
+		parts int
+		want  []int64
+	}{
+		{
+			name:  "three ways with remainders",
+			bill:  models.Invoice{Subtotal: usd(1000), Discount_total: usd(100), Tax_total: usd(91), Service_charge: usd(0)},
+			parts: 3,
+			want:  []int64{331, 330, 330},
+		},
+		{
+			name:  "two ways with a tip",
+			bill:  models.Invoice{Subtotal: usd(1001), Discount_total: usd(0), Tax_total: usd(101), Service_charge: usd(51), Tip: &tip},
+			parts: 2,
+			want:  []int64{627, 626},
+		},
+		{
+			name:  "one part is the whole bill",
+			bill:  models.Invoice{Subtotal: usd(500), Discount_total: usd(50), Tax_total: usd(45), Service_charge: usd(25)},
+			parts: 1,
+			want:  []int64{520},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.bill.Grand_total = tt.bill.Subtotal.Sub(tt.bill.Discount_total).Add(tt.bill.Tax_total).Add(tt.bill.Service_charge)
+			if tt.bill.Tip != nil {
+				tt.bill.Grand_total = tt.bill.Grand_total.Add(*tt.bill.Tip)
+			}
+
+			invoices := splitEvenly(tt.bill, tt.parts)
+			if len(invoices) != tt.parts {
+				t.Fatalf("got %d invoices, want %d", len(invoices), tt.parts)
+			}
+
+			var subtotal, discount, tax, serviceCharge, tips, grandTotal models.Money
+			ids := map[string]bool{}
+			for i, invoice := range invoices {
+				if invoice.Grand_total.Amount-invoices[0].Grand_total.Amount < -1 || invoice.Grand_total.Amount-invoices[0].Grand_total.Amount > 1 {
+					t.Errorf("part %d pays %s, more than a minor unit away from part 1", i+1, invoice.Grand_total)
+				}
+				if invoice.Grand_total.Amount != tt.want[i] {
+					t.Errorf("part %d grand total = %d, want %d", i+1, invoice.Grand_total.Amount, tt.want[i])
+				}
+				own := invoice.Subtotal.Sub(invoice.Discount_total).Add(invoice.Tax_total).Add(invoice.Service_charge)
+				if invoice.Tip != nil {
+					own = own.Add(*invoice.Tip)
+					tips = tips.Add(*invoice.Tip)
+				}
+				if !invoice.Grand_total.Equal(own) || !invoice.Balance_due.Equal(own) {
+					t.Errorf("part %d grand total %s does not add up to its parts %s", i+1, invoice.Grand_total, own)
+				}
+				if invoice.Split == nil || invoice.Split.Type != models.SplitEven || invoice.Split.Part != i+1 || invoice.Split.Parts != tt.parts {
+					t.Errorf("part %d split = %+v", i+1, invoice.Split)
+				}
+				if ids[invoice.Invoice_id] {
+					t.Errorf("part %d reuses invoice id %q", i+1, invoice.Invoice_id)
+				}
+				ids[invoice.Invoice_id] = true
+
+				subtotal = subtotal.Add(invoice.Subtotal)
+				discount = discount.Add(invoice.Discount_total)
+				tax = tax.Add(invoice.Tax_total)
+				serviceCharge = serviceCharge.Add(invoice.Service_charge)
+				grandTotal = grandTotal.Add(invoice.Grand_total)
+			}
+
+			billTip := models.NewMoney(0, "USD")
+			if tt.bill.Tip != nil {
+				billTip = *tt.bill.Tip
+			}
+			billTotal := tt.bill.Grand_total
+			if subtotal != tt.bill.Subtotal || discount != tt.bill.Discount_total || tax != tt.bill.Tax_total || serviceCharge != tt.bill.Service_charge {
+				t.Errorf("parts do not add up to the bill")
+			}
+			if tips.Amount != billTip.Amount || grandTotal.Amount != billTotal.Amount {
+				t.Errorf("parts total %s with %s tips, want %s with %s", grandTotal, tips, billTotal, billTip)
+			}
+		})
+	}
+}
+
+func TestSplitByItem(t *testing.T) {
+	orderItems := []models.OrderItem{{Order_item_id: "a"}, {Order_item_id: "b"}, {Order_item_id: "c"}}
+
+	tests := []struct {
+		name    string
+		groups  [][]string
+		want    [][]string
+		wantErr bool
+	}{
+		{"every item", [][]string{{"a", "c"}, {"b"}}, [][]string{{"a", "c"}, {"b"}}, false},
+		{"some items", [][]string{{"b"}}, [][]string{{"b"}}, false},
+		{"unknown item", [][]string{{"a"}, {"z"}}, nil, true},
+		{"item in two groups", [][]string{{"a", "b"}, {"b"}}, nil, true},
+		{"item twice in a group", [][]string{{"a", "a"}}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			groups, err := splitByItem(orderItems, tt.groups)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("splitByItem error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if len(groups) != len(tt.want) {
+				t.Fatalf("got %d groups, want %d", len(groups), len(tt.want))
+			}
+			for i, group := range groups {
+				if len(group) != len(tt.want[i]) {
+					t.Fatalf("group %d has %d items, want %d", i, len(group), len(tt.want[i]))
+				}
+				for j, orderItem := range group {
+					if orderItem.Order_item_id != tt.want[i][j] {
+						t.Errorf("group %d item %d = %s, want %s", i, j, orderItem.Order_item_id, tt.want[i][j])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSplitBySeat(t *testing.T) {
+	seat := func(number int) *int { return &number }
+
+	tests := []struct {
+		name       string
+		orderItems []models.OrderItem
+		wantSeats  []int
+		wantItems  [][]string
+		wantErr    bool
+	}{
+		{
+			name: "grouped in seat order",
+			orderItems: []models.OrderItem{
+				{Order_item_id: "a", Seat: seat(3)},
+				{Order_item_id: "b", Seat: seat(1)},
+				{Order_item_id: "c", Seat: seat(3)},
+			},
+			wantSeats: []int{1, 3},
+			wantItems: [][]string{{"b"}, {"a", "c"}},
+		},
+		{
+			name:       "item without a seat",
+			orderItems: []models.OrderItem{{Order_item_id: "a", Seat: seat(1)}, {Order_item_id: "b"}},
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			groups, seats, err := splitBySeat(tt.orderItems)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("splitBySeat error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if len(seats) != len(tt.wantSeats) || len(groups) != len(tt.wantItems) {
+				t.Fatalf("got seats %v and %d groups, want %v", seats, len(groups), tt.wantSeats)
+			}
+			for i, group := range groups {
+				if seats[i] != tt.wantSeats[i] {
+					t.Errorf("seat %d = %d, want %d", i, seats[i], tt.wantSeats[i])
+				}
+				for j, orderItem := range group {
+					if orderItem.Order_item_id != tt.wantItems[i][j] {
+						t.Errorf("seat %d item %d = %s, want %s", seats[i], j, orderItem.Order_item_id, tt.wantItems[i][j])
+					}
+				}
+			}
+		})
+	}
+}
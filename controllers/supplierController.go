@@ -0,0 +1,168 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/database"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var supplierCollection *mongo.Collection = database.OpenCollection(database.Client, "supplier")
+
+func GetSuppliers() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		result, err := supplierCollection.Find(c, bson.M{}, options.Find().SetSort(bson.D{{"name", 1}}))
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while listing Suppliers"})
+			return
+		}
+
+		var allSuppliers []bson.M
+		if err = result.All(c, &allSuppliers); err != nil {
+			log.Println(err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while listing Suppliers"})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, allSuppliers)
+	}
+}
+
+func GetSupplier() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var supplier models.Supplier
+		if err := supplierCollection.FindOne(c, bson.M{"supplier_id": ctx.Param("supplier_id")}).Decode(&supplier); err != nil {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Supplier was not found"})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, supplier)
+	}
+}
+
+func CreateSupplier() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var supplier models.Supplier
+
+		if err := ctx.BindJSON(&supplier); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validationErr := validate.Struct(supplier)
+		if validationErr != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		supplier.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		supplier.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		supplier.ID = primitive.NewObjectID()
+		supplier.Supplier_id = supplier.ID.Hex()
+
+		result, insertErr := supplierCollection.InsertOne(c, supplier)
+		if insertErr != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Supplier was not created"})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, result)
+	}
+}
+
+func UpdateSupplier() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var supplier models.Supplier
+		supplierId := ctx.Param("supplier_id")
+
+		if err := ctx.BindJSON(&supplier); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var fields []string
+		var updateObj primitive.D
+
+		if supplier.Name != nil {
+			fields = append(fields, "Name")
+			updateObj = append(updateObj, bson.E{"name", supplier.Name})
+		}
+		if supplier.Contact_name != nil {
+			updateObj = append(updateObj, bson.E{"contact_name", supplier.Contact_name})
+		}
+		if supplier.Phone != nil {
+			updateObj = append(updateObj, bson.E{"phone", supplier.Phone})
+		}
+		if supplier.Email != nil {
+			fields = append(fields, "Email")
+			updateObj = append(updateObj, bson.E{"email", supplier.Email})
+		}
+		if supplier.Lead_time_days != nil {
+			fields = append(fields, "Lead_time_days")
+			updateObj = append(updateObj, bson.E{"lead_time_days", supplier.Lead_time_days})
+		}
+		if len(fields) > 0 {
+			if validationErr := validate.StructPartial(supplier, fields...); validationErr != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+				return
+			}
+		}
+
+		supplier.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj = append(updateObj, bson.E{"updated_at", supplier.Updated_at})
+
+		result, err := supplierCollection.UpdateOne(
+			c,
+			bson.M{"supplier_id": supplierId},
+			bson.D{
+				{"$set", updateObj},
+			},
+		)
+		if err != nil {
+			msg := fmt.Sprintf("Supplier update failed")
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			return
+		}
+		if result.MatchedCount == 0 {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Supplier was not found"})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, result)
+	}
+}
+
+// checkSupplier checks that a supplier referenced by an ingredient or a
+// purchase order exists.
+func checkSupplier(c context.Context, supplierId string) error {
+	count, err := supplierCollection.CountDocuments(c, bson.M{"supplier_id": supplierId})
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("Supplier %s does not exist", supplierId)
+	}
+
+	return nil
+}
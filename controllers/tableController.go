@@ -1,29 +1,297 @@
 package controllers
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
 	"github.com/gin-gonic/gin"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/database"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
+var tableCollection *mongo.Collection = database.OpenCollection(database.Client, "table")
+
 func GetTables() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
+		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		filter := bson.M{}
+		if status := ctx.Query("status"); status != "" {
+			filter["status"] = status
+		}
 
+		result, err := tableCollection.Find(c, filter)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while listing Tables"})
+			return
+		}
+
+		var allTables []bson.M
+		if err = result.All(c, &allTables); err != nil {
+			log.Println(err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while listing Tables"})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, allTables)
 	}
 }
 
 func GetTable() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
+		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		tableId := ctx.Param("table_id")
+		var table models.Table
 
+		err := tableCollection.FindOne(c, bson.M{"table_id": tableId}).Decode(&table)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while fetching the Table"})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, table)
 	}
 }
 
 func CreateTable() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
+		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var table models.Table
+
+		if err := ctx.BindJSON(&table); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validationErr := validate.Struct(table)
+		if validationErr != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		if *table.Number_of_guests > *table.Capacity {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Number of guests exceeds the Table capacity"})
+			return
+		}
 
+		count, err := tableCollection.CountDocuments(c, bson.M{"table_number": table.Table_number})
+		if err != nil {
+			log.Println(err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while checking the Table number"})
+			return
+		}
+		if count > 0 {
+			ctx.JSON(http.StatusConflict, gin.H{"error": "Table number already exists"})
+			return
+		}
+
+		status := models.TableFree
+		if table.Status == nil {
+			table.Status = &status
+		}
+
+		table.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		table.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		table.ID = primitive.NewObjectID()
+		table.Table_id = table.ID.Hex()
+
+		// The unique index on table_number settles a race with another
+		// table created under the same number since the check above.
+		result, insertErr := tableCollection.InsertOne(c, table)
+		if mongo.IsDuplicateKeyError(insertErr) {
+			ctx.JSON(http.StatusConflict, gin.H{"error": "Table number already exists"})
+			return
+		}
+		if insertErr != nil {
+			msg := fmt.Sprintf("Table was not created")
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, result)
 	}
 }
 
 func UpdateTable() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
+		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var table models.Table
+		var foundTable models.Table
+		tableId := ctx.Param("table_id")
+
+		if err := ctx.BindJSON(&table); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := tableCollection.FindOne(c, bson.M{"table_id": tableId}).Decode(&foundTable); err != nil {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "Table was not found"})
+			return
+		}
+
+		var fields []string
+		if table.Number_of_guests != nil {
+			fields = append(fields, "Number_of_guests")
+		}
+		if table.Table_number != nil {
+			fields = append(fields, "Table_number")
+		}
+		if table.Capacity != nil {
+			fields = append(fields, "Capacity")
+		}
+		if table.Status != nil {
+			fields = append(fields, "Status")
+		}
+		if len(fields) > 0 {
+			if validationErr := validate.StructPartial(table, fields...); validationErr != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+				return
+			}
+		}
+
+		var updateObj primitive.D
+
+		if table.Table_number != nil && *table.Table_number != *foundTable.Table_number {
+			count, err := tableCollection.CountDocuments(c, bson.M{"table_number": table.Table_number})
+			if err != nil {
+				log.Println(err)
+				ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while checking the Table number"})
+				return
+			}
+			if count > 0 {
+				ctx.JSON(http.StatusConflict, gin.H{"error": "Table number already exists"})
+				return
+			}
+			updateObj = append(updateObj, bson.E{"table_number", table.Table_number})
+		}
 
+		guests := foundTable.Number_of_guests
+		if table.Number_of_guests != nil {
+			guests = table.Number_of_guests
+			updateObj = append(updateObj, bson.E{"number_of_guests", table.Number_of_guests})
+		}
+
+		capacity := foundTable.Capacity
+		if table.Capacity != nil {
+			capacity = table.Capacity
+			updateObj = append(updateObj, bson.E{"capacity", table.Capacity})
+		}
+
+		if guests != nil && capacity != nil && *guests > *capacity {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Number of guests exceeds the Table capacity"})
+			return
+		}
+
+		if table.Status != nil {
+			updateObj = append(updateObj, bson.E{"status", table.Status})
+			if *table.Status == models.TableFree {
+				updateObj = append(updateObj, bson.E{"order_id", ""})
+			}
+		}
+
+		table.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj = append(updateObj, bson.E{"updated_at", table.Updated_at})
+
+		result, err := tableCollection.UpdateOne(
+			c,
+			bson.M{"table_id": tableId},
+			bson.D{
+				{"$set", updateObj},
+			},
+		)
+		if mongo.IsDuplicateKeyError(err) {
+			ctx.JSON(http.StatusConflict, gin.H{"error": "Table number already exists"})
+			return
+		}
+		if err != nil {
+			msg := fmt.Sprintf("Table update failed")
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, result)
 	}
 }
+
+// setTableStatus moves a table to a new seating state. An empty orderId
+// leaves the table's current order untouched.
+func setTableStatus(c context.Context, tableId string, status string, orderId string) error {
+	updateObj := primitive.D{{"status", status}}
+	if orderId != "" {
+		updateObj = append(updateObj, bson.E{"order_id", orderId})
+	}
+
+	updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	updateObj = append(updateObj, bson.E{"updated_at", updatedAt})
+
+	_, err := tableCollection.UpdateOne(
+		c,
+		bson.M{"table_id": tableId},
+		bson.D{
+			{"$set", updateObj},
+		},
+	)
+
+	return err
+}
+
+// errTableOccupied is returned when an order is seated at a table another
+// order is still using.
+var errTableOccupied = errors.New("Table is occupied by another Order")
+
+// seatTable seats an order at a table. A table seated with, or waiting on
+// the bill of, another order is occupied and is left alone, the check and
+// the update are one write so two orders can not take the same table.
+func seatTable(c context.Context, tableId string, orderId string) error {
+	updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	result, err := tableCollection.UpdateOne(
+		c,
+		bson.M{
+			"table_id": tableId,
+			"$or": bson.A{
+				bson.M{"status": bson.M{"$nin": bson.A{models.TableSeated, models.TableAwaitingBill}}},
+				bson.M{"order_id": orderId},
+			},
+		},
+		bson.D{
+			{"$set", bson.D{{"status", models.TableSeated}, {"order_id", orderId}, {"updated_at", updatedAt}}},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errTableOccupied
+	}
+
+	return nil
+}
+
+// freeTable frees a table seated with the given order. A table that has
+// moved on to another order is left alone.
+func freeTable(c context.Context, tableId string, orderId string) error {
+	updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	_, err := tableCollection.UpdateOne(
+		c,
+		bson.M{"table_id": tableId, "order_id": orderId},
+		bson.D{
+			{"$set", bson.D{{"status", models.TableFree}, {"order_id", ""}, {"updated_at", updatedAt}}},
+		},
+	)
+
+	return err
+}
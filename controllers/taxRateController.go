@@ -0,0 +1,150 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/database"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var taxRateCollection *mongo.Collection = database.OpenCollection(database.Client, "taxRate")
+
+func GetTaxRates() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		result, err := taxRateCollection.Find(c, bson.M{})
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while listing Tax rates"})
+			return
+		}
+
+		var allTaxRates []bson.M
+		if err = result.All(c, &allTaxRates); err != nil {
+			log.Println(err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while listing Tax rates"})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, allTaxRates)
+	}
+}
+
+func CreateTaxRate() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var taxRate models.TaxRate
+
+		if err := ctx.BindJSON(&taxRate); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validationErr := validate.Struct(taxRate)
+		if validationErr != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		count, err := taxRateCollection.CountDocuments(c, bson.M{"category": taxRate.Category})
+		if err != nil {
+			log.Println(err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while checking the Tax rate category"})
+			return
+		}
+		if count > 0 {
+			ctx.JSON(http.StatusConflict, gin.H{"error": "A Tax rate already exists for this category"})
+			return
+		}
+
+		taxRate.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		taxRate.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		taxRate.ID = primitive.NewObjectID()
+		taxRate.Tax_rate_id = taxRate.ID.Hex()
+
+		result, insertErr := taxRateCollection.InsertOne(c, taxRate)
+		if insertErr != nil {
+			msg := fmt.Sprintf("Tax rate was not created")
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, result)
+	}
+}
+
+func UpdateTaxRate() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var taxRate models.TaxRate
+		taxRateId := ctx.Param("tax_rate_id")
+
+		if err := ctx.BindJSON(&taxRate); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var updateObj primitive.D
+
+		if taxRate.Name != nil {
+			updateObj = append(updateObj, bson.E{"name", taxRate.Name})
+		}
+
+		if taxRate.Rate != nil {
+			if validationErr := validate.StructPartial(taxRate, "Rate"); validationErr != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+				return
+			}
+			updateObj = append(updateObj, bson.E{"rate", taxRate.Rate})
+		}
+
+		taxRate.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj = append(updateObj, bson.E{"updated_at", taxRate.Updated_at})
+
+		result, err := taxRateCollection.UpdateOne(
+			c,
+			bson.M{"tax_rate_id": taxRateId},
+			bson.D{
+				{"$set", updateObj},
+			},
+		)
+		if err != nil {
+			msg := fmt.Sprintf("Tax rate update failed")
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, result)
+	}
+}
+
+// taxRateFor is the rate in percent charged on food from a menu category.
+func taxRateFor(c context.Context, category string) (float64, error) {
+	var taxRate models.TaxRate
+
+	err := taxRateCollection.FindOne(c, bson.M{"category": category}).Decode(&taxRate)
+	if err == mongo.ErrNoDocuments {
+		err = taxRateCollection.FindOne(c, bson.M{"category": models.DefaultTaxCategory}).Decode(&taxRate)
+	}
+	if err == mongo.ErrNoDocuments {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return *taxRate.Rate, nil
+}
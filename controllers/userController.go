@@ -1,18 +1,108 @@
 package controllers
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
 	"github.com/gin-gonic/gin"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/database"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/helpers"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/crypto/bcrypt"
 )
 
+var userCollection *mongo.Collection = database.OpenCollection(database.Client, "user")
+
+type RefreshRequest struct {
+	Refresh_token *string `json:"refresh_token" validate:"required"`
+}
+
+type RoleRequest struct {
+	Role *string `json:"role" validate:"required,eq=ADMIN|eq=MANAGER|eq=WAITER|eq=CASHIER|eq=KITCHEN"`
+}
+
 func GetUsers() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
+		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		recordPerPage, err := strconv.Atoi(ctx.Query("recordPerPage"))
+		if err != nil || recordPerPage < 1 {
+			recordPerPage = 10
+		}
+
+		page, err := strconv.Atoi(ctx.Query("page"))
+		if err != nil || page < 1 {
+			page = 1
+		}
+
+		startIndex := (page - 1) * recordPerPage
 
+		matchStage := bson.D{{"$match", bson.D{{}}}}
+		projectStage := bson.D{
+			{
+				"$project", bson.D{
+					{"_id", 0},
+					{"password", 0},
+					{"token", 0},
+					{"refresh_token", 0},
+				},
+			},
+		}
+		skipStage := bson.D{{"$skip", startIndex}}
+		limitStage := bson.D{{"$limit", recordPerPage}}
+
+		result, err := userCollection.Aggregate(c, mongo.Pipeline{matchStage, projectStage, skipStage, limitStage})
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while listing Users"})
+			return
+		}
+
+		var allUsers []bson.M
+		if err = result.All(c, &allUsers); err != nil {
+			log.Println(err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while listing Users"})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, allUsers)
 	}
 }
 
 func GetUser() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
+		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		userId := ctx.Param("user_id")
+		var user models.User
+
+		if err := helpers.MatchUserRoleToUid(ctx, userId); err != nil {
+			ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
 
+		err := userCollection.FindOne(c, bson.M{"user_id": userId}).Decode(&user)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while fetching the User"})
+			return
+		}
+
+		user.Password = nil
+		user.Token = nil
+		user.Refresh_Token = nil
+
+		ctx.JSON(http.StatusOK, user)
 	}
 }
 
@@ -24,20 +114,310 @@ func CreateUser() gin.HandlerFunc {
 
 func SignUp() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
+		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var user models.User
+
+		if err := ctx.BindJSON(&user); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 
+		validationErr := validate.Struct(user)
+		if validationErr != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		count, err := userCollection.CountDocuments(c, bson.M{"email": user.Email})
+		if err != nil {
+			log.Println(err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while checking for the Email"})
+			return
+		}
+		if count > 0 {
+			ctx.JSON(http.StatusConflict, gin.H{"error": "Email already exists"})
+			return
+		}
+
+		count, err = userCollection.CountDocuments(c, bson.M{"phone": user.Phone})
+		if err != nil {
+			log.Println(err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while checking for the Phone number"})
+			return
+		}
+		if count > 0 {
+			ctx.JSON(http.StatusConflict, gin.H{"error": "Phone number already exists"})
+			return
+		}
+
+		// Only admins reach this route, staff start as waiters unless the
+		// admin creating the account gives them another role.
+		if user.Role == nil {
+			role := models.RoleWaiter
+			user.Role = &role
+		}
+
+		password := HashPassword(*user.Password)
+		user.Password = &password
+
+		user.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		user.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		user.ID = primitive.NewObjectID()
+		user.User_id = user.ID.Hex()
+
+		token, refreshToken, err := helpers.GenerateAllTokens(*user.Email, *user.First_name, *user.Last_name, user.User_id, *user.Role)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while generating Tokens"})
+			return
+		}
+		user.Token = &token
+		user.Refresh_Token = &refreshToken
+
+		result, insertErr := userCollection.InsertOne(c, user)
+		if mongo.IsDuplicateKeyError(insertErr) {
+			ctx.JSON(http.StatusConflict, gin.H{"error": "Email or Phone number already exists"})
+			return
+		}
+		if insertErr != nil {
+			msg := fmt.Sprintf("User was not created")
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, result)
 	}
 }
 
 func Login() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
+		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var user models.User
+		var foundUser models.User
+
+		if err := ctx.BindJSON(&user); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if user.Email == nil || user.Password == nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Email and Password are required"})
+			return
+		}
+
+		err := userCollection.FindOne(c, bson.M{"email": user.Email}).Decode(&foundUser)
+		if err != nil {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Email or Password is incorrect"})
+			return
+		}
+
+		passwordIsValid, msg := VerifyPassword(*user.Password, *foundUser.Password)
+		if !passwordIsValid {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": msg})
+			return
+		}
+
+		token, refreshToken, err := helpers.GenerateAllTokens(*foundUser.Email, *foundUser.First_name, *foundUser.Last_name, foundUser.User_id, userRole(foundUser))
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while generating Tokens"})
+			return
+		}
+
+		if err := helpers.UpdateAllTokens(token, refreshToken, foundUser.User_id); err != nil {
+			log.Println(err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while saving Tokens"})
+			return
+		}
+
+		foundUser.Password = nil
+		foundUser.Token = &token
+		foundUser.Refresh_Token = &refreshToken
+
+		ctx.JSON(http.StatusOK, foundUser)
+	}
+}
+
+// RefreshToken exchanges a valid refresh token for a new token pair. The stored
+// refresh token is replaced, so a refresh token can only be used once.
+func RefreshToken() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var request RefreshRequest
+		var foundUser models.User
+
+		if err := ctx.BindJSON(&request); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validationErr := validate.Struct(request)
+		if validationErr != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		claims, msg := helpers.ValidateToken(*request.Refresh_token, helpers.RefreshToken)
+		if msg != "" {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": msg})
+			return
+		}
+
+		err := userCollection.FindOne(c, bson.M{"user_id": claims.Uid, "refresh_token": request.Refresh_token}).Decode(&foundUser)
+		if err != nil {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "refresh token has already been used"})
+			return
+		}
 
+		token, refreshToken, err := helpers.GenerateAllTokens(*foundUser.Email, *foundUser.First_name, *foundUser.Last_name, foundUser.User_id, userRole(foundUser))
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while generating Tokens"})
+			return
+		}
+
+		updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		result, err := userCollection.UpdateOne(
+			c,
+			bson.M{"user_id": foundUser.User_id, "refresh_token": request.Refresh_token},
+			bson.D{
+				{"$set", bson.D{{"token", token}, {"refresh_token", refreshToken}, {"updated_at", updatedAt}}},
+			},
+		)
+		if err != nil {
+			log.Println(err)
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Error occured while saving Tokens"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "refresh token has already been used"})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"token": token, "refresh_token": refreshToken})
 	}
 }
 
+// UpdateUserRole changes the role of a user and signs them out, their tokens
+// carry the old role and are no longer accepted.
+func UpdateUserRole() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var request RoleRequest
+		userId := ctx.Param("user_id")
+
+		if err := ctx.BindJSON(&request); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validationErr := validate.Struct(request)
+		if validationErr != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		result, err := userCollection.UpdateOne(
+			c,
+			bson.M{"user_id": userId},
+			bson.D{
+				{"$set", bson.D{{"role", request.Role}, {"token", nil}, {"refresh_token", nil}, {"updated_at", updatedAt}}},
+			},
+		)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "User role update failed"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, result)
+	}
+}
+
+// BootstrapAdmin creates the first admin from ADMIN_EMAIL and
+// ADMIN_PASSWORD when no account holds that email yet. The upsert only
+// inserts, so running it on every start up never touches an existing user.
+// Only admins sign staff up, so without these settings an admin must
+// already exist.
+func BootstrapAdmin() error {
+	var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	email := os.Getenv("ADMIN_EMAIL")
+	password := os.Getenv("ADMIN_PASSWORD")
+	if email == "" || password == "" {
+		admins, err := userCollection.CountDocuments(c, bson.M{"role": models.RoleAdmin})
+		if err != nil {
+			return err
+		}
+		if admins == 0 {
+			return errors.New("no admin account exists, set ADMIN_EMAIL and ADMIN_PASSWORD to create one")
+		}
+		return nil
+	}
+
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	id := primitive.NewObjectID()
+	firstName := "Admin"
+	admin := bson.D{
+		{"_id", id},
+		{"user_id", id.Hex()},
+		{"first_name", firstName},
+		{"last_name", firstName},
+		{"email", email},
+		{"password", HashPassword(password)},
+		{"role", models.RoleAdmin},
+		{"created_at", now},
+		{"updated_at", now},
+	}
+	if phone := os.Getenv("ADMIN_PHONE"); phone != "" {
+		admin = append(admin, bson.E{"phone", phone})
+	}
+
+	_, err := userCollection.UpdateOne(
+		c,
+		bson.M{"email": email},
+		bson.D{
+			{"$setOnInsert", admin},
+		},
+		options.Update().SetUpsert(true),
+	)
+
+	return err
+}
+
+// userRole treats accounts created before roles existed as waiters.
+func userRole(user models.User) string {
+	if user.Role == nil {
+		return models.RoleWaiter
+	}
+
+	return *user.Role
+}
+
 func HashPassword(password string) string {
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
+	if err != nil {
+		log.Panic(err)
+	}
 
+	return string(bytes)
 }
 
 func VerifyPassword(userPassword string, providePassword string) (bool, string) {
+	err := bcrypt.CompareHashAndPassword([]byte(providePassword), []byte(userPassword))
+	if err != nil {
+		return false, "Email or Password is incorrect"
+	}
 
+	return true, ""
 }
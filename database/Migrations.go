@@ -0,0 +1,213 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Migration rewrites documents stored in an older shape. They are all
+// applied on each start up, so every migration must be safe to run again or
+// be wrapped in once.
+type Migration struct {
+	Name string
+	Run  func(ctx context.Context, client *mongo.Client) error
+}
+
+var Migrations = []Migration{
+	{"order item quantity to count and portion", migrateOrderItemQuantity},
+	{"float prices to money", migrateFloatPrices},
+	{"bill ids of invoiced order items", once("bill ids of invoiced order items", migrateBilledOrderItems)},
+	{"balance due of refunded invoices", migrateRefundedBalances},
+	{"unique table numbers, user emails and phones", createUniqueIndexes},
+}
+
+func RunMigrations(client *mongo.Client) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	for _, migration := range Migrations {
+		if err := migration.Run(ctx, client); err != nil {
+			return fmt.Errorf("migration %q failed: %w", migration.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// once runs a migration that is not safe to run again only until it first
+// succeeds, which is recorded in the migration collection.
+func once(name string, run func(ctx context.Context, client *mongo.Client) error) func(ctx context.Context, client *mongo.Client) error {
+	return func(ctx context.Context, client *mongo.Client) error {
+		migrations := OpenCollection(client, "migration")
+
+		count, err := migrations.CountDocuments(ctx, bson.M{"_id": name})
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+
+		if err := run(ctx, client); err != nil {
+			return err
+		}
+
+		_, err = migrations.InsertOne(ctx, bson.D{{"_id", name}, {"applied_at", time.Now()}})
+		if mongo.IsDuplicateKeyError(err) {
+			return nil
+		}
+		return err
+	}
+}
+
+// migrateOrderItemQuantity moves the old S/M/L quantity strings into the
+// portion field and records a single item ordered.
+func migrateOrderItemQuantity(ctx context.Context, client *mongo.Client) error {
+	_, err := OpenCollection(client, "orderItem").UpdateMany(
+		ctx,
+		bson.M{"quantity": bson.M{"$type": "string"}},
+		mongo.Pipeline{
+			bson.D{{"$set", bson.D{{"portion", "$quantity"}, {"quantity", 1}}}},
+		},
+	)
+
+	return err
+}
+
+// migrateFloatPrices converts prices stored as plain floats into Money
+// documents in the default currency. Invoices are left alone, Money still
+// decodes the floats they were written with.
+func migrateFloatPrices(ctx context.Context, client *mongo.Client) error {
+	currency := models.DefaultCurrency
+	scale := models.MinorUnitsPerMajor(currency)
+
+	toMoney := func(field string) bson.D {
+		return bson.D{
+			{"amount", bson.D{{"$toLong", bson.D{{"$floor", bson.D{{"$add", bson.A{bson.D{{"$multiply", bson.A{field, scale}}}, 0.5}}}}}}}},
+			{"currency", currency},
+		}
+	}
+	numeric := bson.M{"$type": bson.A{"double", "int", "long", "decimal"}}
+
+	_, err := OpenCollection(client, "food").UpdateMany(
+		ctx,
+		bson.M{"price": numeric},
+		mongo.Pipeline{bson.D{{"$set", bson.D{{"price", toMoney("$price")}}}}},
+	)
+	if err != nil {
+		return err
+	}
+
+	portionPrices := bson.D{{"$arrayToObject", bson.D{{"$map", bson.D{
+		{"input", bson.D{{"$objectToArray", "$portion_prices"}}},
+		{"as", "p"},
+		{"in", bson.D{
+			{"k", "$$p.k"},
+			{"v", bson.D{{"$cond", bson.A{bson.D{{"$isNumber", "$$p.v"}}, toMoney("$$p.v"), "$$p.v"}}}},
+		}},
+	}}}}}
+	_, err = OpenCollection(client, "food").UpdateMany(
+		ctx,
+		bson.M{"portion_prices": bson.M{"$type": "object"}},
+		mongo.Pipeline{bson.D{{"$set", bson.D{{"portion_prices", portionPrices}}}}},
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = OpenCollection(client, "orderItem").UpdateMany(
+		ctx,
+		bson.M{"unit_price": numeric},
+		mongo.Pipeline{bson.D{{"$set", bson.D{{"unit_price", toMoney("$unit_price")}}}}},
+	)
+
+	return err
+}
+
+// migrateBilledOrderItems marks the items of invoices created before bills
+// could be split as billed by them. Invoices without lines billed their
+// whole order as it was then, items added after the invoice are left to be
+// billed. It runs once, later invoices record their own items.
+func migrateBilledOrderItems(ctx context.Context, client *mongo.Client) error {
+	cursor, err := OpenCollection(client, "invoice").Find(ctx, bson.M{"split": nil})
+	if err != nil {
+		return err
+	}
+
+	var invoices []models.Invoice
+	if err = cursor.All(ctx, &invoices); err != nil {
+		return err
+	}
+
+	for _, invoice := range invoices {
+		filter := bson.M{"order_id": invoice.Order_id, "bill_id": nil, "created_at": bson.M{"$lte": invoice.Created_at}}
+		if len(invoice.Lines) > 0 {
+			orderItemIds := bson.A{}
+			for _, line := range invoice.Lines {
+				orderItemIds = append(orderItemIds, line.Order_item_id)
+			}
+			filter["order_item_id"] = bson.M{"$in": orderItemIds}
+		}
+
+		_, err := OpenCollection(client, "orderItem").UpdateMany(ctx, filter, bson.D{{"$set", bson.D{{"bill_id", invoice.Invoice_id}}}})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// migrateRefundedBalances recomputes the balance of invoices written while
+// refunds were added back to it, the balance is the total less the payments
+// and never below zero.
+func migrateRefundedBalances(ctx context.Context, client *mongo.Client) error {
+	_, err := OpenCollection(client, "invoice").UpdateMany(
+		ctx,
+		bson.M{
+			"amount_refunded.amount": bson.M{"$gt": 0},
+			"grand_total.amount":     bson.M{"$exists": true},
+			"amount_paid.amount":     bson.M{"$exists": true},
+		},
+		mongo.Pipeline{
+			bson.D{{"$set", bson.D{{"balance_due", bson.D{
+				{"amount", bson.D{{"$max", bson.A{0, bson.D{{"$subtract", bson.A{"$grand_total.amount", "$amount_paid.amount"}}}}}}},
+				{"currency", "$grand_total.currency"},
+			}}}}},
+		},
+	)
+
+	return err
+}
+
+// createUniqueIndexes lets the database settle two requests racing to take
+// the same table number, email or phone. Creating an index that already
+// exists does nothing. Accounts without a phone are left out of its index.
+func createUniqueIndexes(ctx context.Context, client *mongo.Client) error {
+	_, err := OpenCollection(client, "table").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{"table_number", 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = OpenCollection(client, "user").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{"email", 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{"phone", 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"phone": bson.M{"$type": "string"}}),
+		},
+	})
+
+	return err
+}
@@ -0,0 +1,35 @@
+package helpers
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/models"
+)
+
+// CheckUserRole succeeds when the authenticated user holds one of the given
+// roles. Admins are allowed everywhere.
+func CheckUserRole(ctx *gin.Context, roles ...string) error {
+	userRole := ctx.GetString("role")
+	if userRole == models.RoleAdmin {
+		return nil
+	}
+
+	for _, role := range roles {
+		if userRole == role {
+			return nil
+		}
+	}
+
+	return errors.New("Unauthorized to access this resource")
+}
+
+// MatchUserRoleToUid lets a user read their own record, otherwise only
+// admins and managers may.
+func MatchUserRoleToUid(ctx *gin.Context, userId string) error {
+	if ctx.GetString("user_id") == userId {
+		return nil
+	}
+
+	return CheckUserRole(ctx, models.RoleManager)
+}
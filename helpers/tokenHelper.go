@@ -0,0 +1,169 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/database"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	AccessToken  = "access"
+	RefreshToken = "refresh"
+)
+
+type SignedDetails struct {
+	Email      string
+	First_name string
+	Last_name  string
+	Uid        string
+	Role       string
+	Token_type string
+	jwt.RegisteredClaims
+}
+
+var userCollection *mongo.Collection = database.OpenCollection(database.Client, "user")
+
+var SECRET_KEY string = os.Getenv("SECRET_KEY")
+
+// ErrNoSecretKey is returned when SECRET_KEY is not set, tokens signed with
+// an empty key could be forged by anyone.
+var ErrNoSecretKey = errors.New("SECRET_KEY is not set")
+
+// GenerateAllTokens issues a short lived access token and a longer lived refresh token for a user.
+func GenerateAllTokens(email string, firstName string, lastName string, uid string, role string) (signedToken string, signedRefreshToken string, err error) {
+	if SECRET_KEY == "" {
+		return "", "", ErrNoSecretKey
+	}
+
+	now := time.Now()
+
+	claims := &SignedDetails{
+		Email:      email,
+		First_name: firstName,
+		Last_name:  lastName,
+		Uid:        uid,
+		Role:       role,
+		Token_type: AccessToken,
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(24 * time.Hour)),
+		},
+	}
+
+	refreshClaims := &SignedDetails{
+		Uid:        uid,
+		Token_type: RefreshToken,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        primitive.NewObjectID().Hex(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(7 * 24 * time.Hour)),
+		},
+	}
+
+	signedToken, err = jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(SECRET_KEY))
+	if err != nil {
+		return "", "", err
+	}
+
+	signedRefreshToken, err = jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims).SignedString([]byte(SECRET_KEY))
+	if err != nil {
+		return "", "", err
+	}
+
+	return signedToken, signedRefreshToken, nil
+}
+
+// UpdateAllTokens stores the latest token pair on the user, replacing the previous refresh token.
+func UpdateAllTokens(signedToken string, signedRefreshToken string, userId string) error {
+	var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	var updateObj primitive.D
+
+	updateObj = append(updateObj, bson.E{"token", signedToken})
+	updateObj = append(updateObj, bson.E{"refresh_token", signedRefreshToken})
+
+	Updated_at, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	updateObj = append(updateObj, bson.E{"updated_at", Updated_at})
+
+	filter := bson.M{"user_id": userId}
+
+	_, err := userCollection.UpdateOne(
+		c,
+		filter,
+		bson.D{
+			{"$set", updateObj},
+		},
+		options.Update(),
+	)
+
+	return err
+}
+
+// ValidateToken checks the signature and expiry of a token and that it is of the expected type.
+func ValidateToken(signedToken string, tokenType string) (claims *SignedDetails, msg string) {
+	if SECRET_KEY == "" {
+		return nil, "token is invalid"
+	}
+
+	token, err := jwt.ParseWithClaims(
+		signedToken,
+		&SignedDetails{},
+		func(token *jwt.Token) (interface{}, error) {
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, errors.New("unexpected signing method")
+			}
+			return []byte(SECRET_KEY), nil
+		},
+	)
+
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, "token has expired"
+		}
+		return nil, "token is invalid"
+	}
+
+	claims, ok := token.Claims.(*SignedDetails)
+	if !ok || !token.Valid {
+		return nil, "token is invalid"
+	}
+
+	if claims.Token_type != tokenType {
+		return nil, "token is invalid"
+	}
+
+	return claims, ""
+}
+
+// CheckTokenRole makes sure the user of an access token still exists and
+// still holds the role the token was issued with, so a role change takes
+// effect straight away rather than when the token expires.
+func CheckTokenRole(claims *SignedDetails) error {
+	var c, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	var user models.User
+	if err := userCollection.FindOne(c, bson.M{"user_id": claims.Uid}).Decode(&user); err != nil {
+		return errors.New("user no longer exists")
+	}
+
+	role := models.RoleWaiter
+	if user.Role != nil {
+		role = *user.Role
+	}
+	if role != claims.Role {
+		return errors.New("role has changed, please log in again")
+	}
+
+	return nil
+}
@@ -1,12 +1,14 @@
 package main
 
 import (
+	"log"
 	"os"
 
 	"github.com/gin-gonic/gin"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/controllers"
 	"github.com/kwamekyeimonies/restaurant_management_system_backend/database"
-
-	// "github.com/kwamekyeimonies/restaurant_management_system_backend/middlewares"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/helpers"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/middlewares"
 	"github.com/kwamekyeimonies/restaurant_management_system_backend/routes"
 	"go.mongodb.org/mongo-driver/mongo"
 )
@@ -19,16 +21,38 @@ func main() {
 		port = "8080"
 	}
 
+	if helpers.SECRET_KEY == "" {
+		log.Fatal(helpers.ErrNoSecretKey)
+	}
+
+	if err := database.RunMigrations(database.Client); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := controllers.BootstrapAdmin(); err != nil {
+		log.Fatal(err)
+	}
+
 	router := gin.New()
 	router.Use(gin.Logger())
-	// routes.Use(middlewares.Authentication())
+	router.Use(gin.Recovery())
+	router.Use(middlewares.Authentication())
 
+	routes.BundleRoutes(router)
 	routes.FoodRoutes(router)
+	routes.InventoryRoutes(router)
 	routes.InvoiceRoutes(router)
+	routes.KitchenRoutes(router)
 	routes.MenuRoutes(router)
+	routes.NoteRoutes(router)
 	routes.OrderItemRoutes(router)
 	routes.OrderRoutes(router)
+	routes.PaymentRoutes(router)
+	routes.PrintJobRoutes(router)
+	routes.PurchaseOrderRoutes(router)
+	routes.SupplierRoutes(router)
 	routes.TableRoutes(router)
+	routes.TaxRateRoutes(router)
 	routes.UserRoutes(router)
 
 	router.Run(":" + port)
@@ -0,0 +1,69 @@
+package middlewares
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/helpers"
+)
+
+// publicRoutes can be reached without an access token. Sign up is not
+// among them, staff accounts are created by an admin. The refresh route
+// checks its own refresh token, so an expired access token must not block it,
+// and payment webhooks are signed by the provider instead.
+var publicRoutes = map[string]bool{
+	"/users/login":                 true,
+	"/users/refresh":               true,
+	"/payments/webhooks/:provider": true,
+}
+
+func Authentication() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if publicRoutes[ctx.FullPath()] {
+			ctx.Next()
+			return
+		}
+
+		header := ctx.Request.Header.Get("Authorization")
+		if header == "" {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "No Authorization header provided"})
+			return
+		}
+
+		clientToken := strings.TrimPrefix(header, "Bearer ")
+		if clientToken == header || clientToken == "" {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization header must be a Bearer token"})
+			return
+		}
+
+		claims, msg := helpers.ValidateToken(clientToken, helpers.AccessToken)
+		if msg != "" {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": msg})
+			return
+		}
+
+		if err := helpers.CheckTokenRole(claims); err != nil {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx.Set("email", claims.Email)
+		ctx.Set("first_name", claims.First_name)
+		ctx.Set("last_name", claims.Last_name)
+		ctx.Set("user_id", claims.Uid)
+		ctx.Set("role", claims.Role)
+		ctx.Next()
+	}
+}
+
+// Authorize restricts a route to the given roles. It must run after Authentication.
+func Authorize(roles ...string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if err := helpers.CheckUserRole(ctx, roles...); err != nil {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		ctx.Next()
+	}
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	BundleFixed      = "FIXED"
+	BundlePercentOff = "PERCENT_OFF"
+)
+
+// Bundle is a combo meal or set menu ordered as one item, e.g. a lunch set
+// of a starter, a main and a drink. Every slot is filled with one of its
+// foods. A FIXED bundle costs Price, a PERCENT_OFF bundle costs what its
+// foods cost less Percent_off. Supplements and modifiers of the foods picked
+// are charged on top. It can be ordered while its menu is active.
+type Bundle struct {
+	ID          primitive.ObjectID `bson:"_id"`
+	Bundle_id   string             `json:"bundle_id"`
+	Name        *string            `json:"name" validate:"required,min=2,max=100"`
+	Menu_id     *string            `json:"menu_id" validate:"required"`
+	Pricing     string             `json:"pricing" validate:"required,eq=FIXED|eq=PERCENT_OFF"`
+	Price       *Money             `json:"price" validate:"required_if=Pricing FIXED"`
+	Percent_off *float64           `json:"percent_off" validate:"required_if=Pricing PERCENT_OFF,omitempty,gt=0,lt=100"`
+	Slots       []BundleSlot       `json:"slots" validate:"required,min=1,dive"`
+	Created_at  time.Time          `json:"created_at"`
+	Updated_at  time.Time          `json:"updated_at"`
+}
+
+// BundleSlot is one course of a bundle and the foods that can fill it.
+// Supplements are charged, by food id, for the foods that cost extra.
+type BundleSlot struct {
+	Slot_id     string           `json:"slot_id"`
+	Name        string           `json:"name" validate:"required,max=50"`
+	Food_ids    []string         `json:"food_ids" validate:"required,min=1,dive,required"`
+	Supplements map[string]Money `json:"supplements"`
+}
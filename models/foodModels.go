@@ -6,13 +6,53 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+const (
+	StationKitchen = "KITCHEN"
+	StationGrill   = "GRILL"
+	StationFryer   = "FRYER"
+	StationBar     = "BAR"
+	StationPastry  = "PASTRY"
+)
+
+// Station is the prep station that makes the food and gets its kitchen
+// tickets, foods without one go to the main KITCHEN station. Recipe is what
+// a regular portion takes from stock, Portion_recipes overrides it for the
+// other portion sizes. A food is sold out when kitchen staff set Sold_out or
+// when Portions_left, if it is counted, runs out.
 type Food struct {
-	ID         primitive.ObjectID `bson:"_id"`
-	Name       *string            `json:"name" validate:"required, min=2, max=100"`
-	Price      *float64           `json:"price" validate:"required"`
-	Food_image *string            `json:"food_image" validate:"required"`
-	Created_at time.Time          `json:"create_at"`
-	Updated_at time.Time          `json:"updated_at"`
-	Food_id    string             `json:"food_id"`
-	Menu_id    *string            `json:"menu_id" validate:"required"`
+	ID              primitive.ObjectID      `bson:"_id"`
+	Name            *string                 `json:"name" validate:"required,min=2,max=100"`
+	Price           *Money                  `json:"price" validate:"required"`
+	Portion_prices  map[string]Money        `json:"portion_prices" validate:"omitempty,dive,keys,eq=S|eq=M|eq=L,endkeys"`
+	Food_image      *string                 `json:"food_image" validate:"required"`
+	Created_at      time.Time               `json:"create_at"`
+	Updated_at      time.Time               `json:"updated_at"`
+	Food_id         string                  `json:"food_id"`
+	Menu_id         *string                 `json:"menu_id" validate:"required"`
+	Station         *string                 `json:"station" validate:"omitempty,eq=KITCHEN|eq=GRILL|eq=FRYER|eq=BAR|eq=PASTRY"`
+	Sold_out        *bool                   `json:"sold_out"`
+	Portions_left   *int                    `json:"portions_left" validate:"omitempty,min=0"`
+	Recipe          []RecipeItem            `json:"recipe" validate:"omitempty,dive"`
+	Portion_recipes map[string][]RecipeItem `json:"portion_recipes" validate:"omitempty,dive,keys,eq=S|eq=M|eq=L,endkeys,dive"`
+	Modifier_groups []ModifierGroup         `json:"modifier_groups" validate:"omitempty,dive"`
+}
+
+// ModifierGroup is a choice offered with a food, e.g. its cooking level or
+// extras. An order item picks between Min_select and Max_select of its
+// options, a Required group needs at least one.
+type ModifierGroup struct {
+	Group_id   string           `json:"group_id"`
+	Name       string           `json:"name" validate:"required,max=50"`
+	Required   bool             `json:"required"`
+	Min_select int              `json:"min_select" validate:"min=0"`
+	Max_select int              `json:"max_select" validate:"min=1"`
+	Options    []ModifierOption `json:"options" validate:"required,min=1,dive"`
+}
+
+// ModifierOption is one option of a modifier group. Price_delta is added to
+// the unit price when it is picked, removals can take money off.
+type ModifierOption struct {
+	Option_id   string `json:"option_id"`
+	Name        string `json:"name" validate:"required,max=50"`
+	Price_delta Money  `json:"price_delta"`
 }
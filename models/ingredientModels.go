@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Ingredient is a stock item of the kitchen. On_hand is in Unit and can go
+// below zero when more is sold than was counted in. It is low on stock at or
+// below Reorder_level and is restocked up to Par_level from Supplier_id.
+// Last_cost is the cost of one Unit on the last delivery received.
+type Ingredient struct {
+	ID            primitive.ObjectID `bson:"_id"`
+	Ingredient_id string             `json:"ingredient_id"`
+	Name          *string            `json:"name" validate:"required,min=2,max=100"`
+	Unit          *string            `json:"unit" validate:"required,eq=G|eq=KG|eq=ML|eq=L|eq=EACH"`
+	On_hand       *float64           `json:"on_hand" validate:"required"`
+	Reorder_level *float64           `json:"reorder_level" validate:"omitempty,gte=0"`
+	Par_level     *float64           `json:"par_level" validate:"omitempty,gte=0"`
+	Supplier_id   *string            `json:"supplier_id"`
+	Last_cost     *Money             `json:"last_cost"`
+	Created_at    time.Time          `json:"created_at"`
+	Updated_at    time.Time          `json:"updated_at"`
+}
+
+// RecipeItem is how much of an ingredient goes into one portion of a food,
+// or into an order item when it records the stock it used.
+type RecipeItem struct {
+	Ingredient_id string  `json:"ingredient_id" validate:"required"`
+	Quantity      float64 `json:"quantity" validate:"gt=0"`
+}
@@ -6,13 +6,91 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+const (
+	PaymentPending           = "PENDING"
+	PaymentPartiallyPaid     = "PARTIALLY_PAID"
+	PaymentPaid              = "PAID"
+	PaymentPartiallyRefunded = "PARTIALLY_REFUNDED"
+	PaymentRefunded          = "REFUNDED"
+)
+
+const (
+	SplitByItem = "ITEM"
+	SplitBySeat = "SEAT"
+	SplitEven   = "EVEN"
+)
+
+const (
+	DiscountPercent = "PERCENT"
+	DiscountAmount  = "AMOUNT"
+)
+
+// Invoice amounts are tax exclusive. Line_discounts, Order_discount,
+// Service_charge_rate and Tip are supplied by staff, every other amount is
+// computed by the server and stored so a receipt can be reproduced later.
+// Amount_paid, Amount_refunded and Balance_due follow the payments and
+// refunds recorded against it. Split is set on the invoices created by splitting one bill.
 type Invoice struct {
-	ID               primitive.ObjectID `bson:"_id"`
-	Invoice_id       string             `json:"invoice_id"`
-	Order_id         string             `json:"order_id"`
-	Payment_method   *string            `json:"payment_method" validate:"eq=CARD | eq=CASH"`
-	Payment_status   *string            `json:"payment_status" validate:"required, eq:PENDING | eq=PAID"`
-	Payment_due_date time.Time          `json:"Payment_due_date"`
-	Created_at       time.Time          `json:"created_at"`
-	Updated_at       time.Time          `json:"updated_at"`
+	ID                  primitive.ObjectID  `bson:"_id"`
+	Invoice_id          string              `json:"invoice_id"`
+	Order_id            string              `json:"order_id"`
+	Payment_method      *string             `json:"payment_method" validate:"omitempty,eq=CARD|eq=CASH"`
+	Payment_status      *string             `json:"payment_status" validate:"required,eq=PENDING|eq=PARTIALLY_PAID|eq=PAID|eq=PARTIALLY_REFUNDED|eq=REFUNDED"`
+	Payment_due_date    time.Time           `json:"Payment_due_date"`
+	Lines               []InvoiceLine       `json:"lines"`
+	Line_discounts      map[string]Discount `json:"line_discounts" validate:"omitempty,dive"`
+	Order_discount      *Discount           `json:"order_discount"`
+	Service_charge_rate *float64            `json:"service_charge_rate" validate:"omitempty,gte=0,lte=100"`
+	Tip                 *Money              `json:"tip"`
+	Subtotal            Money               `json:"subtotal"`
+	Discount_total      Money               `json:"discount_total"`
+	Tax_total           Money               `json:"tax_total"`
+	Service_charge      Money               `json:"service_charge"`
+	Grand_total         Money               `json:"grand_total"`
+	Amount_paid         Money               `json:"amount_paid"`
+	Amount_refunded     Money               `json:"amount_refunded"`
+	Balance_due         Money               `json:"balance_due"`
+	Split               *InvoiceSplit       `json:"split"`
+	Created_at          time.Time           `json:"created_at"`
+	Updated_at          time.Time           `json:"updated_at"`
+}
+
+// InvoiceLine is a snapshot of an order item at the time it was billed. A
+// voided line keeps its Gross for the record but is no longer charged.
+type InvoiceLine struct {
+	Order_item_id   string   `json:"order_item_id"`
+	Food_id         string   `json:"food_id"`
+	Name            string   `json:"name"`
+	Category        string   `json:"category"`
+	Quantity        int      `json:"quantity"`
+	Portion         string   `json:"portion"`
+	Modifiers       []string `json:"modifiers,omitempty"`
+	Components      []string `json:"components,omitempty"`
+	Unit_price      Money    `json:"unit_price"`
+	Gross           Money    `json:"gross"`
+	Discount_amount Money    `json:"discount_amount"`
+	Net             Money    `json:"net"`
+	Tax_rate        float64  `json:"tax_rate"`
+	Tax             Money    `json:"tax"`
+	Voided          bool     `json:"voided"`
+	Void_reason     string   `json:"void_reason,omitempty"`
+}
+
+// InvoiceSplit records how an invoice was split off its order. Even splits
+// carry every line of the bill but only Part of Parts of its amounts.
+type InvoiceSplit struct {
+	Split_id string `json:"split_id"`
+	Type     string `json:"type"`
+	Part     int    `json:"part"`
+	Parts    int    `json:"parts"`
+	Seat     *int   `json:"seat,omitempty"`
+}
+
+// Discount takes Percent percent off, or a fixed Amount off, depending on
+// its Type.
+type Discount struct {
+	Type    string  `json:"type" validate:"required,eq=PERCENT|eq=AMOUNT"`
+	Percent float64 `json:"percent" validate:"gte=0,lte=100"`
+	Amount  *Money  `json:"amount" validate:"required_if=Type AMOUNT"`
+	Reason  string  `json:"reason"`
 }
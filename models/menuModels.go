@@ -7,12 +7,22 @@ import (
 )
 
 type Menu struct {
-	ID         primitive.ObjectID `bson:"_id"`
-	Name       string             `json:"name" validate:"required"`
-	Category   string             `json:"category" validate:"required"`
-	Start_Date *time.Time         `json:"start_date"`
-	End_Date   *time.Time         `json:"end_date"`
-	Created_at time.Time          `json:"created_date"`
-	Updated_at time.Time          `json:"updated_at"`
-	Menu_id    string             `json:"food_id"`
+	ID           primitive.ObjectID `bson:"_id"`
+	Name         string             `json:"name" validate:"required"`
+	Category     string             `json:"category" validate:"required"`
+	Start_Date   *time.Time         `json:"start_date"`
+	End_Date     *time.Time         `json:"end_date"`
+	Availability []MenuWindow       `json:"availability" validate:"dive"`
+	Created_at   time.Time          `json:"created_date"`
+	Updated_at   time.Time          `json:"updated_at"`
+	Menu_id      string             `json:"menu_id"`
+}
+
+// MenuWindow is a recurring period in which a menu can be ordered, e.g.
+// breakfast from 07:00 to 11:00 every day. A window whose end is before its
+// start runs past midnight. An empty Days list means every day.
+type MenuWindow struct {
+	Days       []string `json:"days" validate:"dive,eq=MON|eq=TUE|eq=WED|eq=THU|eq=FRI|eq=SAT|eq=SUN"`
+	Start_time string   `json:"start_time" validate:"required,datetime=15:04"`
+	End_time   string   `json:"end_time" validate:"required,datetime=15:04"`
 }
@@ -0,0 +1,263 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/x/bsonx/bsoncore"
+)
+
+// DefaultCurrency is used for amounts sent or stored without a currency,
+// including every price stored as a plain float before Money existed.
+var DefaultCurrency = defaultCurrency()
+
+// minorUnits lists the ISO 4217 currencies that do not have two decimals.
+var minorUnits = map[string]int{
+	"BHD": 3,
+	"JPY": 0,
+	"KRW": 0,
+	"KWD": 3,
+	"OMR": 3,
+	"TND": 3,
+	"UGX": 0,
+	"VND": 0,
+	"XAF": 0,
+	"XOF": 0,
+}
+
+// Money is an amount in the minor unit of its currency, e.g. cents for USD.
+// It is stored as {amount, currency}. Plain numbers are still accepted from
+// JSON and BSON and read as major units of DefaultCurrency.
+type Money struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+func defaultCurrency() string {
+	if currency := os.Getenv("CURRENCY"); currency != "" {
+		return strings.ToUpper(currency)
+	}
+
+	return "USD"
+}
+
+func currencyExponent(currency string) int {
+	if exponent, ok := minorUnits[currency]; ok {
+		return exponent
+	}
+
+	return 2
+}
+
+// MinorUnitsPerMajor is how many minor units make one major unit, e.g. 100
+// cents to the dollar.
+func MinorUnitsPerMajor(currency string) int64 {
+	return int64(math.Pow10(currencyExponent(currency)))
+}
+
+func NewMoney(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+// MoneyFromFloat converts an amount in major units, rounding half away from
+// zero to the nearest minor unit.
+func MoneyFromFloat(value float64, currency string) Money {
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	scale := math.Pow(10, float64(currencyExponent(currency)))
+
+	return Money{Amount: int64(math.Round(value * scale)), Currency: currency}
+}
+
+func (m Money) Add(other Money) Money {
+	return Money{Amount: m.Amount + other.Amount, Currency: m.currency(other)}
+}
+
+func (m Money) Sub(other Money) Money {
+	return Money{Amount: m.Amount - other.Amount, Currency: m.currency(other)}
+}
+
+func (m Money) Mul(quantity int64) Money {
+	return Money{Amount: m.Amount * quantity, Currency: m.Currency}
+}
+
+// Times is m times a fractional quantity, e.g. a cost per kilo times 2.5 kg,
+// rounded half away from zero to the minor unit.
+func (m Money) Times(quantity float64) Money {
+	return Money{Amount: int64(math.Round(float64(m.Amount) * quantity)), Currency: m.Currency}
+}
+
+// Percent is percent of m rounded half away from zero to the minor unit.
+func (m Money) Percent(percent float64) Money {
+	return Money{Amount: int64(math.Round(float64(m.Amount) * percent / 100)), Currency: m.Currency}
+}
+
+// Allocate splits m in proportion to weights. The parts always add up to m
+// exactly, leftover minor units go to the largest remainders.
+func (m Money) Allocate(weights []int64) []Money {
+	parts := make([]Money, len(weights))
+	var total int64
+	for i, weight := range weights {
+		parts[i].Currency = m.Currency
+		total += weight
+	}
+	if total == 0 {
+		return parts
+	}
+
+	remainders := make([]int64, len(weights))
+	var allocated int64
+	for i, weight := range weights {
+		parts[i].Amount = m.Amount * weight / total
+		remainders[i] = m.Amount * weight % total
+		allocated += parts[i].Amount
+	}
+
+	for left := m.Amount - allocated; left > 0; left-- {
+		largest := 0
+		for i := range remainders {
+			if remainders[i] > remainders[largest] {
+				largest = i
+			}
+		}
+		parts[largest].Amount++
+		remainders[largest] = -1
+	}
+
+	return parts
+}
+
+func (m Money) Min(other Money) Money {
+	currency := m.currency(other)
+	if other.Amount < m.Amount {
+		return Money{Amount: other.Amount, Currency: currency}
+	}
+
+	return Money{Amount: m.Amount, Currency: currency}
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+func (m Money) IsPositive() bool {
+	return m.Amount > 0
+}
+
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+func (m Money) Equal(other Money) bool {
+	return m.Amount == other.Amount && m.Currency == other.Currency
+}
+
+// Float is the amount in major units, for display only.
+func (m Money) Float() float64 {
+	return float64(m.Amount) / math.Pow(10, float64(currencyExponent(m.Currency)))
+}
+
+func (m Money) String() string {
+	return fmt.Sprintf("%.*f %s", currencyExponent(m.Currency), m.Float(), m.Currency)
+}
+
+// currency lets a zero value with no currency pick up the other operand's.
+// Every amount read from a request or the database is in DefaultCurrency,
+// see checkCurrency, so amounts that are loaded or received always combine.
+func (m Money) currency(other Money) string {
+	if m.Currency == "" {
+		return other.Currency
+	}
+
+	return m.Currency
+}
+
+// checkCurrency fails unless currency is DefaultCurrency. Prices, costs and
+// tenders are all added up together, so a restaurant works in a single
+// currency. Amounts are checked whenever they are decoded, an amount stored
+// under an earlier CURRENCY fails to load rather than being mixed in.
+func checkCurrency(currency string) error {
+	if currency != DefaultCurrency {
+		return fmt.Errorf("amount in %s can not be used, amounts must be in %s", currency, DefaultCurrency)
+	}
+
+	return nil
+}
+
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var value float64
+	if err := json.Unmarshal(data, &value); err == nil {
+		*m = MoneyFromFloat(value, DefaultCurrency)
+		return nil
+	}
+
+	var money struct {
+		Amount   *int64 `json:"amount"`
+		Currency string `json:"currency"`
+	}
+	if err := json.Unmarshal(data, &money); err != nil {
+		return errors.New("money must be a number or an object with an amount and a currency")
+	}
+	if money.Amount == nil {
+		return errors.New("money amount is required")
+	}
+
+	currency := strings.ToUpper(money.Currency)
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	if len(currency) != 3 {
+		return fmt.Errorf("money currency %q is not an ISO 4217 code", money.Currency)
+	}
+	if err := checkCurrency(currency); err != nil {
+		return err
+	}
+
+	*m = Money{Amount: *money.Amount, Currency: currency}
+	return nil
+}
+
+func (m Money) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	return bson.MarshalValue(bson.D{{Key: "amount", Value: m.Amount}, {Key: "currency", Value: m.Currency}})
+}
+
+func (m *Money) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	value := bsoncore.Value{Type: t, Data: data}
+
+	switch t {
+	case bsontype.Double:
+		*m = MoneyFromFloat(value.Double(), DefaultCurrency)
+	case bsontype.Int32:
+		*m = MoneyFromFloat(float64(value.Int32()), DefaultCurrency)
+	case bsontype.Int64:
+		*m = MoneyFromFloat(float64(value.Int64()), DefaultCurrency)
+	case bsontype.EmbeddedDocument:
+		var money struct {
+			Amount   int64  `bson:"amount"`
+			Currency string `bson:"currency"`
+		}
+		if err := bson.Unmarshal(data, &money); err != nil {
+			return err
+		}
+		if money.Currency == "" {
+			money.Currency = DefaultCurrency
+		}
+		if err := checkCurrency(money.Currency); err != nil {
+			return err
+		}
+		*m = Money{Amount: money.Amount, Currency: money.Currency}
+	case bsontype.Null, bsontype.Undefined:
+		*m = Money{}
+	default:
+		return fmt.Errorf("cannot decode %s into Money", t)
+	}
+
+	return nil
+}
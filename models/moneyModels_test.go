@@ -0,0 +1,229 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// otherCurrency is a currency that is not DefaultCurrency.
+func otherCurrency() string {
+	if DefaultCurrency == "EUR" {
+		return "USD"
+	}
+
+	return "EUR"
+}
+
+func TestMoneyUnmarshalJSON(t *testing.T) {
+	other := otherCurrency()
+
+	tests := []struct {
+		name    string
+		input   string
+		want    Money
+		wantErr bool
+	}{
+		{"float in default currency", `12.5`, Money{1250, DefaultCurrency}, false},
+		{"float rounds half away from zero", `0.125`, Money{13, DefaultCurrency}, false},
+		{"negative float", `-0.125`, Money{-13, DefaultCurrency}, false},
+		{"integer", `3`, Money{300, DefaultCurrency}, false},
+		{"object", `{"amount": 999, "currency": "` + DefaultCurrency + `"}`, Money{999, DefaultCurrency}, false},
+		{"object with lower case currency", `{"amount": 5, "currency": "` + strings.ToLower(DefaultCurrency) + `"}`, Money{5, DefaultCurrency}, false},
+		{"object without currency", `{"amount": 5}`, Money{5, DefaultCurrency}, false},
+		{"object in another currency", `{"amount": 5, "currency": "` + other + `"}`, Money{}, true},
+		{"object without amount", `{"currency": "` + DefaultCurrency + `"}`, Money{}, true},
+		{"bad currency code", `{"amount": 5, "currency": "EURO"}`, Money{}, true},
+		{"string", `"12.50"`, Money{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Money
+			err := json.Unmarshal([]byte(tt.input), &got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal(%s) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("Unmarshal(%s) = %+v, want %+v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoneyMarshalJSON(t *testing.T) {
+	data, err := json.Marshal(Money{1250, "USD"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"amount":1250,"currency":"USD"}` {
+		t.Errorf("Marshal = %s", data)
+	}
+}
+
+func TestMoneyBSON(t *testing.T) {
+	other := otherCurrency()
+
+	tests := []struct {
+		name    string
+		value   interface{}
+		want    Money
+		wantErr bool
+	}{
+		{"double", 12.5, Money{1250, DefaultCurrency}, false},
+		{"int32", int32(4), Money{400, DefaultCurrency}, false},
+		{"int64", int64(7), Money{700, DefaultCurrency}, false},
+		{"document", bson.D{{"amount", int64(999)}, {"currency", DefaultCurrency}}, Money{999, DefaultCurrency}, false},
+		{"document without currency", bson.D{{"amount", int64(5)}}, Money{5, DefaultCurrency}, false},
+		{"document in another currency", bson.D{{"amount", int64(999)}, {"currency", other}}, Money{}, true},
+		{"money", Money{-250, DefaultCurrency}, Money{-250, DefaultCurrency}, false},
+		{"money without currency", Money{}, Money{0, DefaultCurrency}, false},
+		{"null", nil, Money{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := bson.Marshal(bson.D{{"price", tt.value}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got struct {
+				Price Money `bson:"price"`
+			}
+			err = bson.Unmarshal(data, &got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decoding %v error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if !tt.wantErr && got.Price != tt.want {
+				t.Errorf("decoded %v as %+v, want %+v", tt.value, got.Price, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoneyFromFloat(t *testing.T) {
+	tests := []struct {
+		value    float64
+		currency string
+		want     Money
+	}{
+		{19.99, "USD", Money{1999, "USD"}},
+		{0.125, "USD", Money{13, "USD"}},
+		{1500, "JPY", Money{1500, "JPY"}},
+		{1.2346, "KWD", Money{1235, "KWD"}},
+		{2.5, "", Money{250, DefaultCurrency}},
+	}
+
+	for _, tt := range tests {
+		if got := MoneyFromFloat(tt.value, tt.currency); got != tt.want {
+			t.Errorf("MoneyFromFloat(%v, %q) = %+v, want %+v", tt.value, tt.currency, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyFloat(t *testing.T) {
+	tests := []struct {
+		money Money
+		want  float64
+	}{
+		{Money{1999, "USD"}, 19.99},
+		{Money{1500, "JPY"}, 1500},
+		{Money{1235, "KWD"}, 1.235},
+		{Money{-50, "EUR"}, -0.5},
+	}
+
+	for _, tt := range tests {
+		if got := tt.money.Float(); got != tt.want {
+			t.Errorf("%+v.Float() = %v, want %v", tt.money, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyAllocate(t *testing.T) {
+	tests := []struct {
+		name    string
+		amount  int64
+		weights []int64
+		want    []int64
+	}{
+		{"even", 900, []int64{1, 1, 1}, []int64{300, 300, 300}},
+		{"remainder to the first largest", 1000, []int64{1, 1, 1}, []int64{334, 333, 333}},
+		{"two left over", 1001, []int64{1, 1, 1}, []int64{334, 334, 333}},
+		{"largest remainder wins", 100, []int64{1, 2}, []int64{33, 67}},
+		{"proportional", 1000, []int64{250, 750}, []int64{250, 750}},
+		{"zero weight gets nothing", 10, []int64{0, 1, 1}, []int64{0, 5, 5}},
+		{"no weight", 10, []int64{0, 0}, []int64{0, 0}},
+		{"single part", 7, []int64{3}, []int64{7}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parts := NewMoney(tt.amount, "USD").Allocate(tt.weights)
+			if len(parts) != len(tt.want) {
+				t.Fatalf("got %d parts, want %d", len(parts), len(tt.want))
+			}
+
+			var total, weight int64
+			for i, part := range parts {
+				if part.Amount != tt.want[i] || part.Currency != "USD" {
+					t.Errorf("part %d = %+v, want %d USD", i, part, tt.want[i])
+				}
+				total += part.Amount
+				weight += tt.weights[i]
+			}
+			if weight > 0 && total != tt.amount {
+				t.Errorf("parts add up to %d, want %d", total, tt.amount)
+			}
+		})
+	}
+}
+
+func TestMoneyPercent(t *testing.T) {
+	tests := []struct {
+		amount  int64
+		percent float64
+		want    int64
+	}{
+		{1000, 10, 100},
+		{999, 12.5, 125},
+		{1005, 10, 101},
+		{1004, 10, 100},
+		{5, 50, 3},
+		{-5, 50, -3},
+		{1234, 0, 0},
+		{1234, 100, 1234},
+	}
+
+	for _, tt := range tests {
+		got := NewMoney(tt.amount, "USD").Percent(tt.percent)
+		if got != NewMoney(tt.want, "USD") {
+			t.Errorf("%d percent %v = %+v, want %d", tt.amount, tt.percent, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyArithmetic(t *testing.T) {
+	tests := []struct {
+		name string
+		got  Money
+		want Money
+	}{
+		{"add", NewMoney(150, "USD").Add(NewMoney(250, "USD")), NewMoney(400, "USD")},
+		{"sub", NewMoney(150, "USD").Sub(NewMoney(250, "USD")), NewMoney(-100, "USD")},
+		{"zero value takes the other currency", Money{}.Add(NewMoney(5, "EUR")), NewMoney(5, "EUR")},
+		{"adding a zero value keeps the currency", NewMoney(5, "EUR").Sub(Money{}), NewMoney(5, "EUR")},
+		{"min", NewMoney(500, "USD").Min(NewMoney(300, "USD")), NewMoney(300, "USD")},
+		{"min keeps the smaller", NewMoney(300, "USD").Min(NewMoney(500, "USD")), NewMoney(300, "USD")},
+		{"mul", NewMoney(250, "USD").Mul(3), NewMoney(750, "USD")},
+		{"times rounds", NewMoney(333, "USD").Times(1.5), NewMoney(500, "USD")},
+	}
+
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %+v, want %+v", tt.name, tt.got, tt.want)
+		}
+	}
+}
@@ -6,11 +6,25 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+const (
+	NoteOrder     = "ORDER"
+	NoteOrderItem = "ORDER_ITEM"
+	NoteTable     = "TABLE"
+	NoteCustomer  = "CUSTOMER"
+)
+
+// Note is left by staff on an order, an order item, a table or a customer,
+// Parent_id is the id of what it is on. Customers are not stored by this
+// API, their notes hang off whatever id the front end uses for them.
 type Note struct {
-	ID         primitive.ObjectID `bson:"_id"`
-	Text       string             `json:"text"`
-	Title      string             `json:"title"`
-	Created_at time.Time          `json:"created_at"`
-	Updated_at time.Time          `json:"updated_at"`
-	Note_id    string             `json:"note_id"`
+	ID          primitive.ObjectID `bson:"_id"`
+	Text        string             `json:"text" validate:"required,max=500"`
+	Title       string             `json:"title" validate:"max=100"`
+	Parent_type *string            `json:"parent_type" validate:"required,eq=ORDER|eq=ORDER_ITEM|eq=TABLE|eq=CUSTOMER"`
+	Parent_id   *string            `json:"parent_id" validate:"required"`
+	Created_by  string             `json:"created_by"`
+	Updated_by  string             `json:"updated_by"`
+	Created_at  time.Time          `json:"created_at"`
+	Updated_at  time.Time          `json:"updated_at"`
+	Note_id     string             `json:"note_id"`
 }
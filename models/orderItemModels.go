@@ -6,13 +6,66 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+const (
+	PrepQueued    = "QUEUED"
+	PrepCooking   = "COOKING"
+	PrepReady     = "READY"
+	PrepServed    = "SERVED"
+	PrepCancelled = "CANCELLED"
+)
+
+const (
+	PortionSmall   = "S"
+	PortionMedium  = "M"
+	PortionLarge   = "L"
+	DefaultPortion = PortionMedium
+)
+
+// Bill_id is the invoice, or the even split, that bills the item. Items
+// without one have not been billed yet. Stock_used is what the item took
+// from stock, so it can be put back exactly, Restocked is set once it and
+// the portions the item counted off have been. Notes are only read when the
+// item is ordered, they are stored as notes on the item. Unit_price includes
+// the price deltas of the Modifiers picked. A bundle is ordered as one item
+// with a Bundle_id instead of a Food_id, its foods are its Components.
 type OrderItem struct {
 	ID            primitive.ObjectID `bson:"_id"`
-	Quantity      *string            `json:"quantity" validate:"required, eq=S| eq=M| eq=L"`
-	Unit_price    *float64           `json:"unit_price" validate:"required"`
+	Quantity      *int               `json:"quantity" validate:"required,min=1"`
+	Portion       *string            `json:"portion" validate:"omitempty,eq=S|eq=M|eq=L"`
+	Unit_price    *Money             `json:"unit_price"`
 	Created_at    time.Time          `json:"created-at"`
 	Updated_at    time.Time          `json:"update_at"`
-	Food_id       *string            `json:"food_id" validate:"required"`
+	Food_id       *string            `json:"food_id" validate:"required_without=Bundle_id,excluded_with=Bundle_id"`
 	Order_item_id string             `json:"order_item_id"`
 	Order_id      string             `json:"order_id" validate:"required"`
+	Prep_status   *string            `json:"prep_status"`
+	Seat          *int               `json:"seat" validate:"omitempty,min=1"`
+	Bill_id       *string            `json:"bill_id"`
+	Stock_used    []RecipeItem       `json:"stock_used"`
+	Restocked     bool               `json:"restocked"`
+	Notes         []string           `json:"notes,omitempty" bson:"-" validate:"omitempty,dive,required,max=500"`
+	Modifiers     []SelectedModifier `json:"modifiers" validate:"omitempty,dive"`
+	Bundle_id     *string            `json:"bundle_id"`
+	Components    []BundleComponent  `json:"components" validate:"required_with=Bundle_id,omitempty,dive"`
+	Version       int64              `json:"version"`
+}
+
+// SelectedModifier is a modifier option picked for an order item. Only the
+// ids are read from clients, the name and price come from the food.
+type SelectedModifier struct {
+	Group_id    string `json:"group_id" validate:"required"`
+	Option_id   string `json:"option_id" validate:"required"`
+	Name        string `json:"name"`
+	Price_delta Money  `json:"price_delta"`
+}
+
+// BundleComponent is the food picked for one slot of a bundle. Only the ids
+// and modifiers are read from clients.
+type BundleComponent struct {
+	Slot_id    string             `json:"slot_id" validate:"required"`
+	Food_id    string             `json:"food_id" validate:"required"`
+	Slot_name  string             `json:"slot_name"`
+	Name       string             `json:"name"`
+	Modifiers  []SelectedModifier `json:"modifiers" validate:"omitempty,dive"`
+	Supplement Money              `json:"supplement"`
 }
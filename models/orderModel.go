@@ -6,11 +6,29 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+const (
+	OrderOpen          = "OPEN"
+	OrderSentToKitchen = "SENT_TO_KITCHEN"
+	OrderServed        = "SERVED"
+	OrderPaid          = "PAID"
+	OrderCancelled     = "CANCELLED"
+)
+
 type Order struct {
-	ID         primitive.ObjectID `bson:"_id"`
-	Order_Date time.Time          `json:"order_date" validate:"required"`
-	Created_at time.Time          `json:"created_at"`
-	Updated_at time.Time          `json:"updated_at"`
-	Order_id   string             `json:"order_id"`
-	Table_id   string             `json:"table_id" validate:"required"`
+	ID             primitive.ObjectID  `bson:"_id"`
+	Order_Date     time.Time           `json:"order_date" validate:"required"`
+	Created_at     time.Time           `json:"created_at"`
+	Updated_at     time.Time           `json:"updated_at"`
+	Order_id       string              `json:"order_id"`
+	Table_id       string              `json:"table_id" validate:"required"`
+	Status         *string             `json:"status"`
+	Status_history []OrderStatusChange `json:"status_history"`
+}
+
+type OrderStatusChange struct {
+	From       string    `json:"from"`
+	To         string    `json:"to"`
+	Reason     string    `json:"reason"`
+	Changed_by string    `json:"changed_by"`
+	Changed_at time.Time `json:"changed_at"`
 }
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	TenderCard = "CARD"
+	TenderCash = "CASH"
+)
+
+const (
+	TenderPending  = "PENDING"
+	TenderSettled  = "SETTLED"
+	TenderDeclined = "DECLINED"
+	TenderFailed   = "FAILED"
+)
+
+// Payment is one tender put towards an invoice. Amount is what the guest
+// handed over, Applied is what went towards the balance and Change is what
+// was given back, which only cash payments can have. Card payments are run
+// through Provider and only count towards the invoice once SETTLED.
+type Payment struct {
+	ID             primitive.ObjectID `bson:"_id"`
+	Payment_id     string             `json:"payment_id"`
+	Invoice_id     string             `json:"invoice_id"`
+	Method         *string            `json:"method" validate:"required,eq=CARD|eq=CASH"`
+	Amount         *Money             `json:"amount" validate:"required"`
+	Applied        Money              `json:"applied"`
+	Change         Money              `json:"change"`
+	Refunded       Money              `json:"refunded"`
+	Reference      string             `json:"reference"`
+	Card_token     string             `json:"card_token" bson:"-"`
+	Status         string             `json:"status"`
+	Provider       string             `json:"provider"`
+	Transaction_id string             `json:"transaction_id"`
+	Created_by     string             `json:"created_by"`
+	Created_at     time.Time          `json:"created_at"`
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	PrintQueued   = "QUEUED"
+	PrintPrinting = "PRINTING"
+	PrintPrinted  = "PRINTED"
+	PrintFailed   = "FAILED"
+)
+
+// PrintJob is a kitchen ticket waiting for the print agent of its station.
+// A job that fails to print is queued again until it has had Max_attempts,
+// Reprint_of is set on jobs that reprint an earlier ticket.
+type PrintJob struct {
+	ID           primitive.ObjectID `bson:"_id"`
+	Print_job_id string             `json:"print_job_id"`
+	Station      string             `json:"station"`
+	Order_id     string             `json:"order_id"`
+	Table_number string             `json:"table_number"`
+	Items        []PrintJobItem     `json:"items"`
+	Status       string             `json:"status"`
+	Attempts     int                `json:"attempts"`
+	Max_attempts int                `json:"max_attempts"`
+	Last_error   string             `json:"last_error,omitempty"`
+	Reprint_of   string             `json:"reprint_of,omitempty"`
+	Created_at   time.Time          `json:"created_at"`
+	Updated_at   time.Time          `json:"updated_at"`
+}
+
+type PrintJobItem struct {
+	Order_item_id string   `json:"order_item_id"`
+	Name          string   `json:"name"`
+	Quantity      int      `json:"quantity"`
+	Portion       string   `json:"portion"`
+	Seat          *int     `json:"seat,omitempty"`
+	Modifiers     []string `json:"modifiers,omitempty"`
+	Notes         []string `json:"notes,omitempty"`
+}
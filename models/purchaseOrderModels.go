@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	PurchaseOrderDraft             = "DRAFT"
+	PurchaseOrderOrdered           = "ORDERED"
+	PurchaseOrderPartiallyReceived = "PARTIALLY_RECEIVED"
+	PurchaseOrderReceived          = "RECEIVED"
+	PurchaseOrderCancelled         = "CANCELLED"
+)
+
+// PurchaseOrder restocks ingredients from one supplier. Its lines can only
+// be changed while it is a DRAFT, every delivery received against it is
+// kept in Deliveries.
+type PurchaseOrder struct {
+	ID                primitive.ObjectID  `bson:"_id"`
+	Purchase_order_id string              `json:"purchase_order_id"`
+	Supplier_id       *string             `json:"supplier_id" validate:"required"`
+	Status            *string             `json:"status" validate:"omitempty,eq=DRAFT|eq=ORDERED"`
+	Lines             []PurchaseOrderLine `json:"lines" validate:"required,min=1,dive"`
+	Total             Money               `json:"total"`
+	Note              string              `json:"note"`
+	Deliveries        []Delivery          `json:"deliveries"`
+	Created_by        string              `json:"created_by"`
+	Ordered_at        *time.Time          `json:"ordered_at"`
+	Created_at        time.Time           `json:"created_at"`
+	Updated_at        time.Time           `json:"updated_at"`
+	Version           int64               `json:"version"`
+}
+
+// PurchaseOrderLine is a quantity of one ingredient, in the ingredient's
+// unit, at Unit_cost per unit. Received is how much of it has arrived.
+type PurchaseOrderLine struct {
+	Ingredient_id string  `json:"ingredient_id" validate:"required"`
+	Quantity      float64 `json:"quantity" validate:"gt=0"`
+	Unit_cost     *Money  `json:"unit_cost" validate:"required"`
+	Received      float64 `json:"received"`
+}
+
+// Delivery is one delivery received against a purchase order. Cost is what
+// it cost at the unit costs it was received at.
+type Delivery struct {
+	Delivery_id string         `json:"delivery_id"`
+	Lines       []DeliveryLine `json:"lines"`
+	Cost        Money          `json:"cost"`
+	Received_by string         `json:"received_by"`
+	Received_at time.Time      `json:"received_at"`
+}
+
+// DeliveryLine is how much of an ingredient arrived. Unit_cost defaults to
+// the cost it was ordered at.
+type DeliveryLine struct {
+	Ingredient_id string  `json:"ingredient_id" validate:"required"`
+	Quantity      float64 `json:"quantity" validate:"gt=0"`
+	Unit_cost     *Money  `json:"unit_cost"`
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	RefundTypeRefund = "REFUND"
+	RefundTypeVoid   = "VOID"
+)
+
+const (
+	ReasonComped      = "COMPED"
+	ReasonWrongItem   = "WRONG_ITEM"
+	ReasonQuality     = "QUALITY"
+	ReasonChangedMind = "CHANGED_MIND"
+	ReasonDuplicate   = "DUPLICATE"
+	ReasonOther       = "OTHER"
+)
+
+// Refund is the audit record of money taken off an invoice, either a refund
+// of what was paid or the void of one of its lines. Amount is what the
+// invoice was reduced by, Tenders is what was actually given back and on
+// which payment.
+type Refund struct {
+	ID            primitive.ObjectID `bson:"_id"`
+	Refund_id     string             `json:"refund_id"`
+	Invoice_id    string             `json:"invoice_id"`
+	Type          string             `json:"type"`
+	Order_item_id string             `json:"order_item_id,omitempty"`
+	Amount        Money              `json:"amount"`
+	Reason_code   *string            `json:"reason_code" validate:"required,eq=COMPED|eq=WRONG_ITEM|eq=QUALITY|eq=CHANGED_MIND|eq=DUPLICATE|eq=OTHER"`
+	Note          string             `json:"note"`
+	Tenders       []RefundTender     `json:"tenders"`
+	Approved_by   string             `json:"approved_by,omitempty"`
+	Created_by    string             `json:"created_by"`
+	Created_at    time.Time          `json:"created_at"`
+}
+
+// RefundTender is the part of a refund given back on one payment. Card
+// tenders are FAILED when the provider would not refund them, they then
+// have to be refunded by hand.
+type RefundTender struct {
+	Payment_id     string `json:"payment_id"`
+	Method         string `json:"method"`
+	Amount         Money  `json:"amount"`
+	Transaction_id string `json:"transaction_id,omitempty"`
+	Status         string `json:"status"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Supplier delivers ingredients. Lead_time_days is how long an order takes
+// to arrive, the reorder report orders enough to last until then.
+type Supplier struct {
+	ID             primitive.ObjectID `bson:"_id"`
+	Supplier_id    string             `json:"supplier_id"`
+	Name           *string            `json:"name" validate:"required,min=2,max=100"`
+	Contact_name   *string            `json:"contact_name"`
+	Phone          *string            `json:"phone"`
+	Email          *string            `json:"email" validate:"omitempty,email"`
+	Lead_time_days *int               `json:"lead_time_days" validate:"omitempty,min=0"`
+	Created_at     time.Time          `json:"created_at"`
+	Updated_at     time.Time          `json:"updated_at"`
+}
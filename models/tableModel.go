@@ -6,10 +6,19 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+const (
+	TableFree         = "FREE"
+	TableSeated       = "SEATED"
+	TableAwaitingBill = "AWAITING_BILL"
+	TableCleaning     = "CLEANING"
+)
+
 type Table struct {
 	ID               primitive.ObjectID `bson:"_id"`
-	Number_of_guests *int               `json:"number_of_guests" validate:"required"`
-	Table_number     *int               `json:"table_number" validate:"required"`
+	Number_of_guests *int               `json:"number_of_guests" validate:"required,min=0"`
+	Table_number     *int               `json:"table_number" validate:"required,min=1"`
+	Capacity         *int               `json:"capacity" validate:"required,min=1"`
+	Status           *string            `json:"status" validate:"omitempty,eq=FREE|eq=SEATED|eq=AWAITING_BILL|eq=CLEANING"`
 	Created_at       time.Time          `json:"create_at"`
 	Updated_at       time.Time          `json:"updated_at"`
 	Table_id         string             `json:"table_id"`
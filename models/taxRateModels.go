@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DefaultTaxCategory is the rate applied to menu categories without a rate
+// of their own.
+const DefaultTaxCategory = "DEFAULT"
+
+type TaxRate struct {
+	ID          primitive.ObjectID `bson:"_id"`
+	Category    *string            `json:"category" validate:"required"`
+	Name        *string            `json:"name" validate:"required"`
+	Rate        *float64           `json:"rate" validate:"required,gte=0,lte=100"`
+	Created_at  time.Time          `json:"created_at"`
+	Updated_at  time.Time          `json:"updated_at"`
+	Tax_rate_id string             `json:"tax_rate_id"`
+}
@@ -0,0 +1,186 @@
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const MockProviderName = "mock"
+
+// Card tokens the mock provider treats specially, every other token is
+// approved.
+const (
+	MockDeclineToken = "tok_decline"
+	MockTimeoutToken = "tok_timeout"
+	MockPendingToken = "tok_pending"
+)
+
+// MockSignatureHeader carries the hex HMAC-SHA256 of a mock webhook body,
+// keyed with PAYMENT_WEBHOOK_SECRET.
+const MockSignatureHeader = "X-Mock-Signature"
+
+// MockProvider simulates a card processor in memory so the payment flow can
+// be exercised end to end without a live one. Pending payments settle when
+// a signed webhook is posted for them.
+type MockProvider struct {
+	Timeout time.Duration
+
+	mu           sync.Mutex
+	transactions map[string]*mockTransaction
+}
+
+type mockTransaction struct {
+	amount   models.Money
+	captured models.Money
+	refunded models.Money
+	voided   bool
+}
+
+func NewMockProvider() *MockProvider {
+	return &MockProvider{Timeout: 30 * time.Second, transactions: map[string]*mockTransaction{}}
+}
+
+// The mock provider approves test cards without moving any money, it is only
+// registered when PAYMENT_PROVIDER asks for it.
+func init() {
+	if strings.ToLower(os.Getenv("PAYMENT_PROVIDER")) == MockProviderName {
+		Register(NewMockProvider())
+	}
+}
+
+func (p *MockProvider) Name() string {
+	return MockProviderName
+}
+
+func (p *MockProvider) Authorize(ctx context.Context, request Request) (Result, error) {
+	switch request.Card_token {
+	case MockDeclineToken:
+		return Result{Status: StatusDeclined, Message: "card declined by the issuer"}, ErrDeclined
+	case MockTimeoutToken:
+		select {
+		case <-ctx.Done():
+		case <-time.After(p.Timeout):
+		}
+		return Result{}, ErrTimeout
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	transactionId := "mock_" + primitive.NewObjectID().Hex()
+	p.transactions[transactionId] = &mockTransaction{amount: request.Amount}
+
+	status := StatusApproved
+	if request.Card_token == MockPendingToken {
+		status = StatusPending
+	}
+
+	return Result{Transaction_id: transactionId, Status: status}, nil
+}
+
+func (p *MockProvider) Capture(ctx context.Context, transactionId string, amount models.Money) (Result, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	transaction, ok := p.transactions[transactionId]
+	if !ok {
+		return Result{}, ErrUnknown
+	}
+	if transaction.voided {
+		return Result{}, errors.New("authorization has been voided")
+	}
+	if amount.Amount > transaction.amount.Amount {
+		return Result{}, fmt.Errorf("capture of %s is more than the %s authorized", amount, transaction.amount)
+	}
+
+	transaction.captured = amount
+	return Result{Transaction_id: transactionId, Status: StatusSettled}, nil
+}
+
+func (p *MockProvider) Refund(ctx context.Context, transactionId string, amount models.Money) (Result, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	transaction, ok := p.transactions[transactionId]
+	if !ok {
+		return Result{}, ErrUnknown
+	}
+	if transaction.refunded.Add(amount).Amount > transaction.captured.Amount {
+		return Result{}, fmt.Errorf("refund of %s is more than what is left of the %s captured", amount, transaction.captured)
+	}
+
+	transaction.refunded = transaction.refunded.Add(amount)
+	return Result{Transaction_id: transactionId, Status: StatusSettled}, nil
+}
+
+func (p *MockProvider) Void(ctx context.Context, transactionId string) (Result, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	transaction, ok := p.transactions[transactionId]
+	if !ok {
+		return Result{}, ErrUnknown
+	}
+	if transaction.captured.IsPositive() {
+		return Result{}, errors.New("a captured transaction can only be refunded")
+	}
+
+	transaction.voided = true
+	return Result{Transaction_id: transactionId, Status: StatusSettled}, nil
+}
+
+// ParseWebhook reads a {"transaction_id", "status"} body signed with
+// PAYMENT_WEBHOOK_SECRET. Post one to settle or decline a payment made with
+// MockPendingToken.
+func (p *MockProvider) ParseWebhook(r *http.Request) (WebhookEvent, error) {
+	secret := os.Getenv("PAYMENT_WEBHOOK_SECRET")
+	if secret == "" {
+		return WebhookEvent{}, errors.New("PAYMENT_WEBHOOK_SECRET is not set")
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return WebhookEvent{}, err
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	signature, err := hex.DecodeString(r.Header.Get(MockSignatureHeader))
+	if err != nil || !hmac.Equal(signature, mac.Sum(nil)) {
+		return WebhookEvent{}, errors.New("webhook signature does not match")
+	}
+
+	var event struct {
+		Transaction_id string `json:"transaction_id"`
+		Status         string `json:"status"`
+	}
+	if err := json.Unmarshal(body, &event); err != nil {
+		return WebhookEvent{}, err
+	}
+	if event.Status != StatusSettled && event.Status != StatusDeclined {
+		return WebhookEvent{}, fmt.Errorf("webhook status %q is not SETTLED or DECLINED", event.Status)
+	}
+
+	// A settled pending payment is captured in full on the provider's side.
+	p.mu.Lock()
+	if transaction, ok := p.transactions[event.Transaction_id]; ok && event.Status == StatusSettled {
+		transaction.captured = transaction.amount
+	}
+	p.mu.Unlock()
+
+	return WebhookEvent{Transaction_id: event.Transaction_id, Status: event.Status}, nil
+}
@@ -0,0 +1,88 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/models"
+)
+
+const (
+	StatusApproved = "APPROVED"
+	StatusPending  = "PENDING"
+	StatusDeclined = "DECLINED"
+	StatusSettled  = "SETTLED"
+)
+
+var (
+	ErrDeclined = errors.New("payment was declined")
+	ErrTimeout  = errors.New("payment provider did not answer in time")
+	ErrUnknown  = errors.New("transaction is unknown to the payment provider")
+)
+
+// Request is a card payment to be authorized. Card_token stands in for the
+// card, raw card numbers never reach this server.
+type Request struct {
+	Amount     models.Money
+	Card_token string
+	Reference  string
+}
+
+// Result is what the provider made of a request. A PENDING result settles
+// later and is reported through the provider's webhook.
+type Result struct {
+	Transaction_id string
+	Status         string
+	Message        string
+}
+
+// WebhookEvent is a settlement notification sent by a provider.
+type WebhookEvent struct {
+	Transaction_id string
+	Status         string
+}
+
+// Provider processes card payments. Authorize reserves the amount, Capture
+// takes it, Void releases an authorization that was not captured and Refund
+// gives back some or all of a captured amount.
+type Provider interface {
+	Name() string
+	Authorize(ctx context.Context, request Request) (Result, error)
+	Capture(ctx context.Context, transactionId string, amount models.Money) (Result, error)
+	Refund(ctx context.Context, transactionId string, amount models.Money) (Result, error)
+	Void(ctx context.Context, transactionId string) (Result, error)
+	ParseWebhook(r *http.Request) (WebhookEvent, error)
+}
+
+var providers = map[string]Provider{}
+
+// Register makes a provider available by name, for PAYMENT_PROVIDER and the
+// webhook route.
+func Register(provider Provider) {
+	providers[provider.Name()] = provider
+}
+
+// Lookup finds a registered provider by name.
+func Lookup(name string) (Provider, bool) {
+	provider, ok := providers[name]
+	return provider, ok
+}
+
+// Default is the provider named by PAYMENT_PROVIDER. Card payments are not
+// available until one is configured.
+func Default() (Provider, error) {
+	name := strings.ToLower(os.Getenv("PAYMENT_PROVIDER"))
+	if name == "" {
+		return nil, errors.New("no payment provider is configured, set PAYMENT_PROVIDER")
+	}
+
+	provider, ok := Lookup(name)
+	if !ok {
+		return nil, errors.New("payment provider " + name + " is not registered")
+	}
+
+	return provider, nil
+}
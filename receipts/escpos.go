@@ -0,0 +1,34 @@
+package receipts
+
+import "bytes"
+
+var (
+	escInit        = []byte{0x1b, 0x40}
+	escAlignCenter = []byte{0x1b, 0x61, 0x01}
+	escAlignLeft   = []byte{0x1b, 0x61, 0x00}
+	escDoubleOn    = []byte{0x1d, 0x21, 0x11}
+	escDoubleOff   = []byte{0x1d, 0x21, 0x00}
+	escFeedAndCut  = []byte{0x1d, 0x56, 0x42, 0x03}
+)
+
+// ESCPOS renders the receipt as ESC/POS commands, ready to be written to a
+// thermal printer as is. The restaurant name is printed double size and the
+// paper is cut at the end.
+func ESCPOS(r Receipt) []byte {
+	var buffer bytes.Buffer
+
+	buffer.Write(escInit)
+	buffer.Write(escAlignCenter)
+	buffer.Write(escDoubleOn)
+	buffer.WriteString(ascii(r.Restaurant) + "\n")
+	buffer.Write(escDoubleOff)
+	buffer.Write(escAlignLeft)
+
+	for _, line := range r.Text()[len(wrap(r.Restaurant, Width)):] {
+		buffer.WriteString(line + "\n")
+	}
+
+	buffer.Write(escFeedAndCut)
+
+	return buffer.Bytes()
+}
@@ -0,0 +1,76 @@
+package receipts
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+const (
+	pdfFontSize   = 9
+	pdfLeading    = 11
+	pdfMargin     = 12
+	pdfPageWidth  = 2*pdfMargin + Width*pdfFontSize*6/10
+	pdfLinesPerPg = 120
+)
+
+// PDF renders the receipt as a PDF in Courier, on pages as wide as an 80mm
+// roll. It only uses the standard fonts, so no font is embedded.
+func PDF(r Receipt) []byte {
+	lines := r.Text()
+
+	var pages [][]string
+	for len(lines) > pdfLinesPerPg {
+		pages = append(pages, lines[:pdfLinesPerPg])
+		lines = lines[pdfLinesPerPg:]
+	}
+	pages = append(pages, lines)
+
+	// Objects 1 and 2 are the catalog and the page tree, 3 is the font and
+	// every page then takes two objects, the page and its content stream.
+	var objects []string
+	kids := make([]string, len(pages))
+	for i, page := range pages {
+		pageId, contentId := 4+2*i, 5+2*i
+		kids[i] = fmt.Sprintf("%d 0 R", pageId)
+		height := 2*pdfMargin + len(page)*pdfLeading
+
+		var content bytes.Buffer
+		fmt.Fprintf(&content, "BT /F1 %d Tf %d TL %d %d Td\n", pdfFontSize, pdfLeading, pdfMargin, height-pdfMargin)
+		for _, line := range page {
+			fmt.Fprintf(&content, "(%s) '\n", pdfEscape(line))
+		}
+		content.WriteString("ET")
+
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", pdfPageWidth, height, contentId),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", content.Len(), content.String()),
+		)
+	}
+	objects = append([]string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Courier >>",
+	}, objects...)
+
+	var buffer bytes.Buffer
+	buffer.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = buffer.Len()
+		fmt.Fprintf(&buffer, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+
+	xref := buffer.Len()
+	fmt.Fprintf(&buffer, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buffer, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buffer, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	return buffer.Bytes()
+}
+
+func pdfEscape(text string) string {
+	return strings.NewReplacer(`\`, `\\`, `(`, `\(`, `)`, `\)`).Replace(text)
+}
@@ -0,0 +1,229 @@
+package receipts
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/models"
+)
+
+// Width is the number of characters on a line of a receipt, what fits an
+// 80mm thermal roll in its normal font.
+const Width = 42
+
+// Receipt is everything printed on a guest's receipt. Amounts that are
+// zero are left off.
+type Receipt struct {
+	Restaurant     string
+	Address        string
+	Phone          string
+	Invoice_id     string
+	Table_number   string
+	Split          string
+	Date           time.Time
+	Lines          []Line
+	Subtotal       models.Money
+	Discount       models.Money
+	Taxes          []Tax
+	Service_charge models.Money
+	Tip            models.Money
+	Total          models.Money
+	Payments       []Payment
+	Refunded       models.Money
+	Balance_due    models.Money
+	Footer         string
+}
+
+type Line struct {
+	Name       string
+	Quantity   int
+	Portion    string
+	Modifiers  []string
+	Components []string
+	Amount     models.Money
+	Voided     bool
+}
+
+// Tax is the tax charged at one rate, in percent. A zero Rate prints as
+// plain tax.
+type Tax struct {
+	Rate   float64
+	Amount models.Money
+}
+
+type Payment struct {
+	Method string
+	Amount models.Money
+	Change models.Money
+}
+
+// New starts a receipt with the restaurant details from RESTAURANT_NAME,
+// RESTAURANT_ADDRESS, RESTAURANT_PHONE and RECEIPT_FOOTER.
+func New() Receipt {
+	receipt := Receipt{
+		Restaurant: os.Getenv("RESTAURANT_NAME"),
+		Address:    os.Getenv("RESTAURANT_ADDRESS"),
+		Phone:      os.Getenv("RESTAURANT_PHONE"),
+		Footer:     os.Getenv("RECEIPT_FOOTER"),
+	}
+	if receipt.Restaurant == "" {
+		receipt.Restaurant = "Restaurant"
+	}
+	if receipt.Footer == "" {
+		receipt.Footer = "Thank you for dining with us!"
+	}
+
+	return receipt
+}
+
+// Text lays the receipt out as Width wide lines of plain ASCII. The first
+// lines up to the first blank one are the header.
+func (r Receipt) Text() []string {
+	var lines []string
+	center := func(text string) {
+		for _, part := range wrap(text, Width) {
+			lines = append(lines, strings.Repeat(" ", (Width-len(part))/2)+part)
+		}
+	}
+	amount := func(label string, money models.Money) {
+		lines = append(lines, columns(label, money.String()))
+	}
+	rule := strings.Repeat("-", Width)
+
+	center(r.Restaurant)
+	if r.Address != "" {
+		center(r.Address)
+	}
+	if r.Phone != "" {
+		center(r.Phone)
+	}
+	lines = append(lines, "")
+
+	lines = append(lines, columns("Invoice", r.Invoice_id))
+	if r.Table_number != "" {
+		lines = append(lines, columns("Table", r.Table_number))
+	}
+	if r.Split != "" {
+		lines = append(lines, columns("Split", r.Split))
+	}
+	lines = append(lines, columns("Date", r.Date.Format("2006-01-02 15:04")))
+	lines = append(lines, rule)
+
+	for _, line := range r.Lines {
+		name := fmt.Sprintf("%d x %s", line.Quantity, line.Name)
+		if line.Portion != "" && line.Portion != models.DefaultPortion {
+			name += " (" + line.Portion + ")"
+		}
+		price := line.Amount.String()
+		if line.Voided {
+			price = "VOID"
+		}
+		lines = append(lines, columns(name, price))
+		for _, component := range line.Components {
+			lines = append(lines, columns("  - "+component, ""))
+		}
+		for _, modifier := range line.Modifiers {
+			lines = append(lines, columns("  + "+modifier, ""))
+		}
+	}
+	lines = append(lines, rule)
+
+	amount("Subtotal", r.Subtotal)
+	if r.Discount.IsPositive() {
+		amount("Discount", r.Discount.Mul(-1))
+	}
+	for _, tax := range r.Taxes {
+		label := "Tax"
+		if tax.Rate != 0 {
+			label = fmt.Sprintf("Tax %g%%", tax.Rate)
+		}
+		amount(label, tax.Amount)
+	}
+	if r.Service_charge.IsPositive() {
+		amount("Service charge", r.Service_charge)
+	}
+	if r.Tip.IsPositive() {
+		amount("Tip", r.Tip)
+	}
+	amount("TOTAL", r.Total)
+
+	if len(r.Payments) > 0 {
+		lines = append(lines, rule)
+		for _, payment := range r.Payments {
+			amount("Paid "+strings.ToLower(payment.Method), payment.Amount)
+			if payment.Change.IsPositive() {
+				amount("Change", payment.Change)
+			}
+		}
+	}
+	if r.Refunded.IsPositive() {
+		amount("Refunded", r.Refunded)
+	}
+	if r.Balance_due.IsPositive() {
+		amount("BALANCE DUE", r.Balance_due)
+	}
+
+	lines = append(lines, "")
+	center(r.Footer)
+
+	return lines
+}
+
+// Render renders the receipt as text, escpos or pdf, with its content type.
+func Render(r Receipt, format string) ([]byte, string, error) {
+	switch format {
+	case "", "text":
+		return []byte(strings.Join(r.Text(), "\n") + "\n"), "text/plain; charset=utf-8", nil
+	case "escpos":
+		return ESCPOS(r), "application/octet-stream", nil
+	case "pdf":
+		return PDF(r), "application/pdf", nil
+	}
+
+	return nil, "", fmt.Errorf("receipt format %q is not text, escpos or pdf", format)
+}
+
+// columns puts left and right on one line, shortening left if they do not
+// fit.
+func columns(left string, right string) string {
+	left, right = ascii(left), ascii(right)
+	room := Width - len(right) - 1
+	if room < 1 {
+		return right
+	}
+	if len(left) > room {
+		left = left[:room]
+	}
+
+	return left + strings.Repeat(" ", Width-len(left)-len(right)) + right
+}
+
+func wrap(text string, width int) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(ascii(text)) {
+		if line != "" && len(line)+1+len(word) > width {
+			lines = append(lines, line)
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+
+	return append(lines, line)
+}
+
+// ascii replaces what a thermal printer's code page or a PDF base font may
+// not have.
+func ascii(text string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 32 || r > 126 {
+			return '?'
+		}
+		return r
+	}, text)
+}
@@ -0,0 +1,81 @@
+package receipts
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/models"
+)
+
+// TicketText lays a kitchen ticket out as Width wide lines, the first one
+// is the station.
+func TicketText(job models.PrintJob) []string {
+	lines := []string{
+		job.Station,
+		columns("Table "+job.Table_number, job.Created_at.Format("15:04")),
+		"Order " + lastChars(job.Order_id, 6),
+	}
+	if job.Reprint_of != "" {
+		lines = append(lines, "*** REPRINT ***")
+	}
+	lines = append(lines, strings.Repeat("=", Width))
+
+	for _, item := range job.Items {
+		name := fmt.Sprintf("%d x %s", item.Quantity, item.Name)
+		if item.Portion != "" && item.Portion != models.DefaultPortion {
+			name += " (" + item.Portion + ")"
+		}
+		seat := ""
+		if item.Seat != nil {
+			seat = fmt.Sprintf("S%d", *item.Seat)
+		}
+		lines = append(lines, columns(name, seat))
+		for _, modifier := range item.Modifiers {
+			lines = append(lines, "  + "+ascii(modifier))
+		}
+		for _, note := range item.Notes {
+			for i, line := range wrap(note, Width-5) {
+				prefix := "     "
+				if i == 0 {
+					prefix = "  ** "
+				}
+				lines = append(lines, prefix+line)
+			}
+		}
+	}
+
+	return lines
+}
+
+// RenderTicket renders a kitchen ticket as text or escpos, with its content
+// type.
+func RenderTicket(job models.PrintJob, format string) ([]byte, string, error) {
+	lines := TicketText(job)
+
+	switch format {
+	case "", "text":
+		return []byte(strings.Join(lines, "\n") + "\n"), "text/plain; charset=utf-8", nil
+	case "escpos":
+		var buffer bytes.Buffer
+		buffer.Write(escInit)
+		buffer.Write(escDoubleOn)
+		buffer.WriteString(lines[0] + "\n")
+		buffer.Write(escDoubleOff)
+		for _, line := range lines[1:] {
+			buffer.WriteString(line + "\n")
+		}
+		buffer.Write(escFeedAndCut)
+		return buffer.Bytes(), "application/octet-stream", nil
+	}
+
+	return nil, "", fmt.Errorf("ticket format %q is not text or escpos", format)
+}
+
+func lastChars(text string, n int) string {
+	if len(text) <= n {
+		return text
+	}
+
+	return text[len(text)-n:]
+}
@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/controllers"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/middlewares"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/models"
+)
+
+func BundleRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/bundles", controllers.GetBundles())
+	incomingRoutes.GET("/bundles/:bundle_id", controllers.GetBundle())
+	incomingRoutes.POST("/bundles", middlewares.Authorize(models.RoleManager), controllers.CreateBundle())
+	incomingRoutes.PATCH("/bundles/:bundle_id", middlewares.Authorize(models.RoleManager), controllers.UpdateBundle())
+}
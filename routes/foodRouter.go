@@ -3,11 +3,14 @@ package routes
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/kwamekyeimonies/restaurant_management_system_backend/controllers"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/middlewares"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/models"
 )
 
 func FoodRoutes(incomingRoutes *gin.Engine) {
 	incomingRoutes.GET("/foods", controllers.GetFoods())
 	incomingRoutes.GET("/foods/:food_id", controllers.GetFood())
-	incomingRoutes.POST("/foods", controllers.CreateFood())
-	incomingRoutes.PATCH("/foods/:food_id", controllers.UpdateFood())
+	incomingRoutes.POST("/foods", middlewares.Authorize(models.RoleManager), controllers.CreateFood())
+	incomingRoutes.PATCH("/foods/:food_id", middlewares.Authorize(models.RoleManager), controllers.UpdateFood())
+	incomingRoutes.PATCH("/foods/:food_id/availability", middlewares.Authorize(models.RoleManager, models.RoleKitchen), controllers.UpdateFoodAvailability())
 }
@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/controllers"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/middlewares"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/models"
+)
+
+func InventoryRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/inventory", middlewares.Authorize(models.RoleManager, models.RoleKitchen), controllers.GetInventory())
+	incomingRoutes.GET("/inventory/reorder", middlewares.Authorize(models.RoleManager, models.RoleKitchen), controllers.GetReorderReport())
+	incomingRoutes.GET("/ingredients/:ingredient_id", middlewares.Authorize(models.RoleManager, models.RoleKitchen), controllers.GetIngredient())
+	incomingRoutes.POST("/ingredients", middlewares.Authorize(models.RoleManager), controllers.CreateIngredient())
+	incomingRoutes.PATCH("/ingredients/:ingredient_id", middlewares.Authorize(models.RoleManager, models.RoleKitchen), controllers.UpdateIngredient())
+}
@@ -3,11 +3,20 @@ package routes
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/kwamekyeimonies/restaurant_management_system_backend/controllers"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/middlewares"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/models"
 )
 
 func InvoiceRoutes(incomingRoutes *gin.Engine) {
 	incomingRoutes.GET("/invoice", controllers.GetInvoices())
 	incomingRoutes.GET("/invoices/:invoice_id", controllers.GetInvoice())
-	incomingRoutes.POST("/invoices", controllers.CreateInvoice())
-	incomingRoutes.PATCH("/invoices/:invoice_id", controllers.UpdateInvoice())
+	incomingRoutes.GET("/invoices/:invoice_id/receipt", middlewares.Authorize(models.RoleManager, models.RoleWaiter, models.RoleCashier), controllers.GetInvoiceReceipt())
+	incomingRoutes.POST("/invoices", middlewares.Authorize(models.RoleManager, models.RoleWaiter, models.RoleCashier), controllers.CreateInvoice())
+	incomingRoutes.POST("/invoices/split", middlewares.Authorize(models.RoleManager, models.RoleWaiter, models.RoleCashier), controllers.SplitInvoice())
+	incomingRoutes.PATCH("/invoices/:invoice_id", middlewares.Authorize(models.RoleManager, models.RoleWaiter, models.RoleCashier), controllers.UpdateInvoice())
+	incomingRoutes.GET("/invoices/:invoice_id/payments", middlewares.Authorize(models.RoleManager, models.RoleCashier), controllers.GetPayments())
+	incomingRoutes.POST("/invoices/:invoice_id/payments", middlewares.Authorize(models.RoleManager, models.RoleCashier), controllers.CreatePayment())
+	incomingRoutes.GET("/invoices/:invoice_id/refunds", middlewares.Authorize(models.RoleManager, models.RoleCashier), controllers.GetRefunds())
+	incomingRoutes.POST("/invoices/:invoice_id/refunds", middlewares.Authorize(models.RoleManager, models.RoleCashier), controllers.CreateRefund())
+	incomingRoutes.POST("/invoices/:invoice_id/lines/:order_item_id/void", middlewares.Authorize(models.RoleManager, models.RoleWaiter, models.RoleCashier), controllers.VoidInvoiceLine())
 }
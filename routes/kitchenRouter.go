@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/controllers"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/middlewares"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/models"
+)
+
+func KitchenRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/kitchen/queue", middlewares.Authorize(models.RoleKitchen, models.RoleManager, models.RoleWaiter), controllers.GetKitchenQueue())
+	incomingRoutes.GET("/kitchen/stream", middlewares.Authorize(models.RoleKitchen, models.RoleManager, models.RoleWaiter), controllers.KitchenStream())
+	incomingRoutes.POST("/kitchen/items/:order_item_id/bump", middlewares.Authorize(models.RoleKitchen, models.RoleManager, models.RoleWaiter), controllers.BumpOrderItem())
+}
@@ -3,11 +3,14 @@ package routes
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/kwamekyeimonies/restaurant_management_system_backend/controllers"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/middlewares"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/models"
 )
 
 func MenuRoutes(incomingRoutes *gin.Engine) {
 	incomingRoutes.GET("/menus", controllers.GetMenus())
+	incomingRoutes.GET("/menus/active", controllers.GetActiveMenus())
 	incomingRoutes.GET("/menus/:menu_id", controllers.GetMenu())
-	incomingRoutes.POST("/menus", controllers.CreateMenu())
-	incomingRoutes.PATCH("/menus/menu_id", controllers.UpdateMenu())
+	incomingRoutes.POST("/menus", middlewares.Authorize(models.RoleManager), controllers.CreateMenu())
+	incomingRoutes.PATCH("/menus/:menu_id", middlewares.Authorize(models.RoleManager), controllers.UpdateMenu())
 }
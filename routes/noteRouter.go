@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/controllers"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/middlewares"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/models"
+)
+
+func NoteRoutes(incomingRoutes *gin.Engine) {
+	staff := middlewares.Authorize(models.RoleManager, models.RoleWaiter, models.RoleCashier, models.RoleKitchen)
+
+	incomingRoutes.GET("/notes", staff, controllers.GetNotes())
+	incomingRoutes.GET("/notes/:note_id", staff, controllers.GetNote())
+	incomingRoutes.POST("/notes", staff, controllers.CreateNote())
+	incomingRoutes.PATCH("/notes/:note_id", staff, controllers.UpdateNote())
+	incomingRoutes.DELETE("/notes/:note_id", staff, controllers.DeleteNote())
+}
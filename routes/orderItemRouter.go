@@ -3,12 +3,14 @@ package routes
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/kwamekyeimonies/restaurant_management_system_backend/controllers"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/middlewares"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/models"
 )
 
 func OrderItemRoutes(incomingRoutes *gin.Engine) {
 	incomingRoutes.GET("/orderitems", controllers.GetOrderItems())
 	incomingRoutes.GET("/orderitems/:orderitem_id", controllers.GetOrderItem())
-	incomingRoutes.POST("/orderitems", controllers.CreateOrderItem())
+	incomingRoutes.POST("/orderitems", middlewares.Authorize(models.RoleManager, models.RoleWaiter), controllers.CreateOrderItem())
 	incomingRoutes.GET("/oderitems-order/:order_id", controllers.GetOrderItemsByOrder())
-	incomingRoutes.PATCH("/orderitems/:orderitem_id", controllers.UpdateOrderItem())
+	incomingRoutes.PATCH("/orderitems/:orderitem_id", middlewares.Authorize(models.RoleManager, models.RoleWaiter), controllers.UpdateOrderItem())
 }
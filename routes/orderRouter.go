@@ -3,11 +3,14 @@ package routes
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/kwamekyeimonies/restaurant_management_system_backend/controllers"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/middlewares"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/models"
 )
 
 func OrderRoutes(incomingRoutes *gin.Engine) {
 	incomingRoutes.GET("/order", controllers.GetOrders())
 	incomingRoutes.GET("/orders/:order_id", controllers.GetOrder())
-	incomingRoutes.POST("/orders", controllers.CreateOrder())
-	incomingRoutes.PATCH("/orders/:order_id", controllers.UpdateOrder())
+	incomingRoutes.POST("/orders", middlewares.Authorize(models.RoleManager, models.RoleWaiter), controllers.CreateOrder())
+	incomingRoutes.PATCH("/orders/:order_id", middlewares.Authorize(models.RoleManager, models.RoleWaiter), controllers.UpdateOrder())
+	incomingRoutes.POST("/orders/:order_id/transition", middlewares.Authorize(models.RoleManager, models.RoleWaiter, models.RoleCashier, models.RoleKitchen), controllers.TransitionOrder())
 }
@@ -0,0 +1,10 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/controllers"
+)
+
+func PaymentRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.POST("/payments/webhooks/:provider", controllers.PaymentWebhook())
+}
@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/controllers"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/middlewares"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/models"
+)
+
+func PrintJobRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/print-jobs", middlewares.Authorize(models.RoleKitchen, models.RoleManager), controllers.GetPrintJobs())
+	incomingRoutes.POST("/print-jobs/:print_job_id/ack", middlewares.Authorize(models.RoleKitchen, models.RoleManager), controllers.AckPrintJob())
+	incomingRoutes.POST("/print-jobs/:print_job_id/reprint", middlewares.Authorize(models.RoleKitchen, models.RoleManager, models.RoleWaiter), controllers.ReprintPrintJob())
+}
@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/controllers"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/middlewares"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/models"
+)
+
+func PurchaseOrderRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/purchase-orders", middlewares.Authorize(models.RoleManager, models.RoleKitchen), controllers.GetPurchaseOrders())
+	incomingRoutes.GET("/purchase-orders/:purchase_order_id", middlewares.Authorize(models.RoleManager, models.RoleKitchen), controllers.GetPurchaseOrder())
+	incomingRoutes.POST("/purchase-orders", middlewares.Authorize(models.RoleManager), controllers.CreatePurchaseOrder())
+	incomingRoutes.PATCH("/purchase-orders/:purchase_order_id", middlewares.Authorize(models.RoleManager), controllers.UpdatePurchaseOrder())
+	incomingRoutes.POST("/purchase-orders/:purchase_order_id/receive", middlewares.Authorize(models.RoleManager, models.RoleKitchen), controllers.ReceivePurchaseOrder())
+}
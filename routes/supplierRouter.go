@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/controllers"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/middlewares"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/models"
+)
+
+func SupplierRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/suppliers", middlewares.Authorize(models.RoleManager, models.RoleKitchen), controllers.GetSuppliers())
+	incomingRoutes.GET("/suppliers/:supplier_id", middlewares.Authorize(models.RoleManager, models.RoleKitchen), controllers.GetSupplier())
+	incomingRoutes.POST("/suppliers", middlewares.Authorize(models.RoleManager), controllers.CreateSupplier())
+	incomingRoutes.PATCH("/suppliers/:supplier_id", middlewares.Authorize(models.RoleManager), controllers.UpdateSupplier())
+}
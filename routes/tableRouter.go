@@ -3,11 +3,13 @@ package routes
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/kwamekyeimonies/restaurant_management_system_backend/controllers"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/middlewares"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/models"
 )
 
 func TableRoutes(incomingRoutes *gin.Engine) {
 	incomingRoutes.GET("/table", controllers.GetTables())
 	incomingRoutes.GET("/tables/:table_id", controllers.GetTable())
-	incomingRoutes.POST("/tables", controllers.CreateTable())
-	incomingRoutes.PATCH("/tables/:table_id", controllers.UpdateTable())
+	incomingRoutes.POST("/tables", middlewares.Authorize(models.RoleManager), controllers.CreateTable())
+	incomingRoutes.PATCH("/tables/:table_id", middlewares.Authorize(models.RoleManager), controllers.UpdateTable())
 }
@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/controllers"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/middlewares"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/models"
+)
+
+func TaxRateRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/tax-rates", controllers.GetTaxRates())
+	incomingRoutes.POST("/tax-rates", middlewares.Authorize(models.RoleManager), controllers.CreateTaxRate())
+	incomingRoutes.PATCH("/tax-rates/:tax_rate_id", middlewares.Authorize(models.RoleManager), controllers.UpdateTaxRate())
+}
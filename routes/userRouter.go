@@ -3,11 +3,15 @@ package routes
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/kwamekyeimonies/restaurant_management_system_backend/controllers"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/middlewares"
+	"github.com/kwamekyeimonies/restaurant_management_system_backend/models"
 )
 
 func UserRoutes(incomingRoutes *gin.Engine) {
-	incomingRoutes.GET("/users", controllers.GetUsers())
+	incomingRoutes.GET("/users", middlewares.Authorize(models.RoleManager), controllers.GetUsers())
 	incomingRoutes.GET("/users/:user_id", controllers.GetUser())
-	incomingRoutes.POST("/users/signup", controllers.SignUp())
+	incomingRoutes.PATCH("/users/:user_id/role", middlewares.Authorize(models.RoleAdmin), controllers.UpdateUserRole())
+	incomingRoutes.POST("/users/signup", middlewares.Authorize(models.RoleAdmin), controllers.SignUp())
 	incomingRoutes.POST("/users/login", controllers.Login())
+	incomingRoutes.POST("/users/refresh", controllers.RefreshToken())
 }